@@ -0,0 +1,262 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+// modes of authenticating to harbor, selected by --harbor-auth-mode
+const (
+	AuthModeBasic         = "basic"
+	AuthModeRobot         = "robot"
+	AuthModeBearer        = "bearer"
+	AuthModeOIDCCLISecret = "oidc-cli-secret"
+	AuthModeSession       = "session"
+)
+
+// robotPrefix is the prefix of the names of the robot accounts.
+const robotPrefix = "robot$"
+
+const csrfTokenHeader = "X-Harbor-CSRF-Token"
+
+// sessionLoginBackoff is how long a failed session login is not retried, not to lock the account.
+const sessionLoginBackoff = 10 * time.Second
+
+// Authenticator sets the credentials of the harbor user on the requests to harbor.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// sessionAuthenticator is implemented by the authenticators which keep a login session,
+// Reset drops it after harbor answered 401, the next request logs in again.
+type sessionAuthenticator interface {
+	Authenticator
+	Reset()
+}
+
+// doFunc sends a request to harbor, e.g. HarborClient.doLimited.
+type doFunc func(req *http.Request) (*http.Response, error)
+
+// newAuthenticator returns the Authenticator of the auth mode of opts,
+// do sends the requests of the session login.
+func newAuthenticator(opts *HarborOpts, do doFunc, logger log.FieldLogger) (Authenticator, error) {
+	switch opts.AuthMode {
+	case "", AuthModeBasic, AuthModeOIDCCLISecret:
+		// the CLI secret of an OIDC user is sent as the password
+		return &basicAuth{opts: opts}, nil
+	case AuthModeRobot:
+		return &basicAuth{opts: opts, prefix: robotPrefix}, nil
+	case AuthModeBearer:
+		if opts.TokenFile == "" {
+			return nil, fmt.Errorf("--harbor-token-file is required by the %s auth mode", AuthModeBearer)
+		}
+		return &bearerAuth{secret: newSecretFile(opts.TokenFile, opts.Redactor(), logger)}, nil
+	case AuthModeSession:
+		return newSessionAuth(opts, do)
+	default:
+		return nil, fmt.Errorf("invalid auth mode: %s", opts.AuthMode)
+	}
+}
+
+// basicAuth sends the username and password, the robot accounts get the robot$ prefix if missing.
+type basicAuth struct {
+	opts   *HarborOpts
+	prefix string
+}
+
+func (a *basicAuth) username() string {
+	if a.prefix != "" && !strings.HasPrefix(a.opts.Username, a.prefix) {
+		return a.prefix + a.opts.Username
+	}
+	return a.opts.Username
+}
+
+func (a *basicAuth) Authenticate(req *http.Request) error {
+	password, err := a.opts.getPassword()
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(a.username(), password)
+	return nil
+}
+
+// bearerAuth sends the token of the file, which is reread when it changes.
+type bearerAuth struct {
+	secret *secretFile
+}
+
+func (a *bearerAuth) Authenticate(req *http.Request) error {
+	token, err := a.secret.get()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// sessionAuth logs in by the form of the harbor UI and sends the session cookie,
+// for the harbors behind the SSO proxies which only pass the cookies through.
+type sessionAuth struct {
+	opts     *HarborOpts
+	do       doFunc
+	loginUrl string
+
+	mu          sync.Mutex
+	jar         http.CookieJar // nil until logged in
+	loginErr    error
+	loginFailed time.Time
+}
+
+func newSessionAuth(opts *HarborOpts, do doFunc) (*sessionAuth, error) {
+	u, err := url.Parse(opts.Url)
+	if err != nil {
+		return nil, err
+	}
+	// the login form is served by the UI, e.g. https://harbor.dev/c/login for https://harbor.dev/api/v2.0
+	u.Path, u.RawQuery = "/c/login", ""
+	return &sessionAuth{opts: opts, do: do, loginUrl: u.String()}, nil
+}
+
+func (a *sessionAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jar == nil {
+		if a.loginErr != nil && time.Since(a.loginFailed) < sessionLoginBackoff {
+			return a.loginErr
+		}
+		jar, err := a.login()
+		if err != nil {
+			a.loginErr, a.loginFailed = err, time.Now()
+			return err
+		}
+		a.jar, a.loginErr = jar, nil
+	}
+
+	for _, c := range a.jar.Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+	return nil
+}
+
+func (a *sessionAuth) Reset() {
+	a.mu.Lock()
+	a.jar = nil
+	a.mu.Unlock()
+}
+
+// login fetches the CSRF token and cookie harbor v2 requires on the POSTs, then posts the login form.
+func (a *sessionAuth) login() (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := a.opts.getPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", a.opts.Url+systemInfoUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.opts.setHeaders(req)
+	resp, err := a.send(jar, req)
+	if err != nil {
+		return nil, err
+	}
+	csrfToken := resp.Header.Get(csrfTokenHeader)
+
+	form := url.Values{
+		"principal": {a.opts.Username},
+		"password":  {password},
+	}
+	req, err = http.NewRequest("POST", a.loginUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	a.opts.setHeaders(req)
+	if csrfToken != "" {
+		req.Header.Set(csrfTokenHeader, csrfToken)
+	}
+	resp, err = a.send(jar, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &harborclient.StatusError{Endpoint: "/c/login", Code: resp.StatusCode, Status: resp.Status}
+	}
+	return jar, nil
+}
+
+// send sends the request of the login with the cookies of jar, and keeps the cookies of the response in jar.
+// The body of the response is closed.
+func (a *sessionAuth) send(jar http.CookieJar, req *http.Request) (*http.Response, error) {
+	for _, c := range jar.Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+	resp, err := a.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	jar.SetCookies(req.URL, resp.Cookies())
+	return resp, nil
+}
+
+// authenticateError is the failure of Authenticator.Authenticate, e.g. the session login.
+type authenticateError struct {
+	err error
+}
+
+func (e *authenticateError) Error() string {
+	return "authenticate to harbor: " + e.err.Error()
+}
+
+func (e *authenticateError) Unwrap() error {
+	return e.err
+}
+
+// do sends the GET to the endpoint with the header, and with the credentials if auth,
+// the session expired is renewed and the request is retried once.
+func (h *HarborClient) do(endpoint string, auth bool, header http.Header) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		req, err := http.NewRequest("GET", h.Opts.Url+endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		h.Opts.setHeaders(req)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if auth && h.auth != nil {
+			if err := h.auth.Authenticate(req); err != nil {
+				return nil, &authenticateError{err}
+			}
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		resp, err := h.doLimited(req)
+		if err != nil {
+			return nil, err
+		}
+
+		sa, ok := h.auth.(sessionAuthenticator)
+		if !auth || !ok || retried || resp.StatusCode != http.StatusUnauthorized {
+			return resp, nil
+		}
+		resp.Body.Close()
+		sa.Reset()
+	}
+}
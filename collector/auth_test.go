@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionLoginLimited(t *testing.T) {
+	global := NewLimiter(0, 0, 1)
+	var inFlight []int // of the global limiter, when harbor answers the login requests
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api" + systemInfoUrl:
+			inFlight = append(inFlight, len(global.inFlight))
+			http.SetCookie(w, &http.Cookie{Name: "__csrf", Value: "csrf-cookie", Path: "/"})
+			w.Header().Set(csrfTokenHeader, "csrf-token")
+			io.WriteString(w, `{}`)
+		case "/c/login":
+			inFlight = append(inFlight, len(global.inFlight))
+			if c, err := r.Cookie("__csrf"); err != nil || c.Value != "csrf-cookie" || r.Header.Get(csrfTokenHeader) != "csrf-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if r.PostFormValue("principal") != "admin" || r.PostFormValue("password") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "session", Path: "/"})
+		default:
+			if c, err := r.Cookie("sid"); err != nil || c.Value != "session" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `[]`)
+		}
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.AuthMode = AuthModeSession
+	opts.SetPassword("secret")
+	e, err := NewExporter(WithHarborOpts(opts), WithGlobalLimiter(global))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := get(t, e.client, "/projects"); body != "[]" {
+		t.Errorf("body %q, want []", body)
+	}
+	if len(inFlight) != 2 || inFlight[0] != 1 || inFlight[1] != 1 {
+		t.Errorf("in flight requests of the global limiter during the login are %v, want [1 1]", inFlight)
+	}
+	if n := len(global.inFlight); n != 0 {
+		t.Errorf("%d requests are still in flight", n)
+	}
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// responseCache memoizes the responses of harbor, several scrapers request the same data,
+// e.g. the projects or the systeminfo, which is fetched once then.
+// The identical requests at the same time are sent once, the responses are kept
+// for the scrape or for the TTL across the scrapes.
+type responseCache struct {
+	ttl   time.Duration // 0 for the scrape
+	stats *cacheStats
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time // zero for never, the cache is dropped after the scrape
+}
+
+// cacheStats counts the cache lookups of a client, shared by its caches of the scrapes.
+type cacheStats struct {
+	hits   uint64
+	misses uint64
+}
+
+func newResponseCache(ttl time.Duration, stats *cacheStats) *responseCache {
+	return &responseCache{ttl: ttl, stats: stats, entries: make(map[string]cacheEntry)}
+}
+
+func (c *responseCache) lookup(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (c *responseCache) store(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if c.ttl > 0 {
+		now := time.Now()
+		expires = now.Add(c.ttl)
+		// drop the expired entries, e.g. the pages of the projects which are gone
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = cacheEntry{body: body, expires: expires}
+}
+
+// get returns the cached response of key, or the one of fetch which is cached on success,
+// the callers waiting for the same key share the response and count as the hits.
+// The returned body is shared, the callers must not modify it.
+func (c *responseCache) get(key string, fetch func() ([]byte, error)) (body []byte, hit bool, err error) {
+	if body, ok := c.lookup(key); ok {
+		atomic.AddUint64(&c.stats.hits, 1)
+		return body, true, nil
+	}
+
+	// shared of singleflight is also true for the caller which fetched, so it is told by fetched
+	fetched := false
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		fetched = true
+		body, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, body)
+		return body, nil
+	})
+	if fetched {
+		atomic.AddUint64(&c.stats.misses, 1)
+	} else {
+		atomic.AddUint64(&c.stats.hits, 1)
+	}
+	if err != nil {
+		return nil, !fetched, err
+	}
+	return v.([]byte), !fetched, nil
+}
+
+func (s *cacheStats) collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(cacheHitsMetric.Desc(), prometheus.CounterValue, float64(atomic.LoadUint64(&s.hits)))
+	ch <- prometheus.MustNewConstMetric(cacheMissesMetric.Desc(), prometheus.CounterValue, float64(atomic.LoadUint64(&s.misses)))
+}
+
+// withScrapeCache returns a shallow copy of the client whose requests are cached for a scrape,
+// the cache of the TTL is shared by the scrapes instead.
+func (h *HarborClient) withScrapeCache() *HarborClient {
+	if h.cacheStats == nil || !h.Opts.ResponseCache {
+		return h
+	}
+	c := *h
+	if c.cache == nil {
+		c.cache = newResponseCache(0, h.cacheStats)
+	}
+	return &c
+}
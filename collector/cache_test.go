@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHarbor answers the requests with their path and counts them by the path,
+// a status other than 200 is answered for the paths of fail.
+type countingHarbor struct {
+	*httptest.Server
+	mu   sync.Mutex
+	hits map[string]int
+	fail map[string]int
+}
+
+func newCountingHarbor(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *countingHarbor {
+	h := &countingHarbor{hits: make(map[string]int), fail: make(map[string]int)}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		h.hits[r.URL.Path]++
+		code := h.fail[r.URL.Path]
+		h.mu.Unlock()
+		if handler != nil {
+			handler(w, r)
+		}
+		if code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+		io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *countingHarbor) count(path string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hits[path]
+}
+
+func (h *countingHarbor) client(t *testing.T, ttl time.Duration) *HarborClient {
+	opts := DefaultHarborOpts()
+	opts.Url = h.URL + "/api"
+	opts.CacheTTL = ttl
+	return newTestClient(t, opts)
+}
+
+func checkCacheStats(t *testing.T, client *HarborClient, hits, misses uint64) {
+	t.Helper()
+	if h, m := atomic.LoadUint64(&client.cacheStats.hits), atomic.LoadUint64(&client.cacheStats.misses); h != hits || m != misses {
+		t.Errorf("cache hits %d, misses %d, want %d and %d", h, m, hits, misses)
+	}
+}
+
+func request(t *testing.T, client *HarborClient, endpoint string) {
+	t.Helper()
+	body, err := client.request(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/api" + endpoint; string(body) != want {
+		t.Errorf("body %q, want %q", body, want)
+	}
+}
+
+func TestResponseCacheScrape(t *testing.T) {
+	harbor := newCountingHarbor(t, nil)
+	client := harbor.client(t, 0)
+
+	scrape := client.withScrapeCache()
+	request(t, scrape, "/projects")
+	request(t, scrape, "/projects")
+	request(t, scrape, "/users")
+	if n := harbor.count("/api/projects"); n != 1 {
+		t.Errorf("/projects requested %d times in a scrape, want 1", n)
+	}
+	checkCacheStats(t, client, 1, 2)
+
+	// the next scrape requests again
+	request(t, client.withScrapeCache(), "/projects")
+	if n := harbor.count("/api/projects"); n != 2 {
+		t.Errorf("/projects requested %d times in two scrapes, want 2", n)
+	}
+	checkCacheStats(t, client, 1, 3)
+
+	// the failed responses are not kept
+	harbor.mu.Lock()
+	harbor.fail["/api/users"] = http.StatusInternalServerError
+	harbor.mu.Unlock()
+	scrape = client.withScrapeCache()
+	for i := 0; i < 2; i++ {
+		if _, err := scrape.request("/users"); err == nil {
+			t.Errorf("the failed response is answered without an error")
+		}
+	}
+	if n := harbor.count("/api/users"); n != 3 {
+		t.Errorf("/users requested %d times, want 3", n)
+	}
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	harbor := newCountingHarbor(t, nil)
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.ResponseCache = false
+	client := newTestClient(t, opts)
+
+	scrape := client.withScrapeCache()
+	request(t, scrape, "/projects")
+	request(t, scrape, "/projects")
+	if n := harbor.count("/api/projects"); n != 2 {
+		t.Errorf("/projects requested %d times, want 2", n)
+	}
+	checkCacheStats(t, client, 0, 0)
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	harbor := newCountingHarbor(t, nil)
+	client := harbor.client(t, 200*time.Millisecond)
+
+	request(t, client.withScrapeCache(), "/projects")
+	request(t, client.withScrapeCache(), "/projects")
+	if n := harbor.count("/api/projects"); n != 1 {
+		t.Errorf("/projects requested %d times within the TTL, want 1", n)
+	}
+	checkCacheStats(t, client, 1, 1)
+
+	time.Sleep(250 * time.Millisecond)
+	request(t, client.withScrapeCache(), "/projects")
+	if n := harbor.count("/api/projects"); n != 2 {
+		t.Errorf("/projects requested %d times after the TTL, want 2", n)
+	}
+	checkCacheStats(t, client, 1, 2)
+}
+
+func TestResponseCacheSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	harbor := newCountingHarbor(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	client := harbor.client(t, 0)
+	scrape := client.withScrapeCache()
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request(t, scrape, "/projects")
+		}()
+	}
+
+	// let the other callers wait for the request in flight before it is answered
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := harbor.count("/api/projects"); n != 1 {
+		t.Errorf("/projects requested %d times by %d callers at the same time, want 1", n, callers)
+	}
+	checkCacheStats(t, client, callers-1, 1)
+}
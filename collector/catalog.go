@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MetricInfo is an entry of the metric catalog.
+type MetricInfo struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Help   string   `json:"help"`
+	Labels []string `json:"labels"`
+	// Collectors exposing the metric, empty for the metrics of the exporter itself
+	Collectors []MetricCollector `json:"collectors"`
+}
+
+// MetricCollector is a collector exposing the metric and the harbor versions it supports.
+type MetricCollector struct {
+	Name     string `json:"name"`
+	Versions string `json:"versions"`
+}
+
+// MetricCatalog returns every metric the exporter and the registered scrapers could expose,
+// ordered by name, the scrapers which don't declare their metrics are left out.
+func (r *Registry) MetricCatalog() []MetricInfo {
+	byName := map[string]*MetricInfo{}
+	add := func(s *MetricSpec) *MetricInfo {
+		info, ok := byName[s.Name]
+		if !ok {
+			info = &MetricInfo{
+				Name:       s.Name,
+				Type:       s.TypeName(),
+				Help:       s.Help,
+				Labels:     append([]string{}, s.Labels...),
+				Collectors: []MetricCollector{},
+			}
+			byName[s.Name] = info
+		}
+		return info
+	}
+
+	for _, s := range exporterMetrics() {
+		add(s)
+	}
+	for _, scraper := range r.infos {
+		ms, ok := scraper.Scraper.(MetricScraper)
+		if !ok {
+			continue
+		}
+		for _, s := range ms.Metrics() {
+			info := add(s)
+			info.Collectors = append(info.Collectors, MetricCollector{
+				Name:     scraper.Name(),
+				Versions: scraper.SupportedVersions().String(),
+			})
+		}
+	}
+
+	catalog := make([]MetricInfo, 0, len(byName))
+	for _, info := range byName {
+		catalog = append(catalog, *info)
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// WriteMetricCatalog writes the MetricCatalog as a markdown table or json, e.g. for --print-metrics.
+func (r *Registry) WriteMetricCatalog(w io.Writer, format string) error {
+	catalog := r.MetricCatalog()
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(catalog)
+	case "markdown", "md":
+	default:
+		return fmt.Errorf("unknown metric catalog format %q", format)
+	}
+
+	fmt.Fprintln(w, "| Metric | Type | Help | Labels | Collectors (harbor versions) |")
+	fmt.Fprintln(w, "| ------ | ---- | ---- | ------ | ---------------------------- |")
+	for _, m := range catalog {
+		collectors := "all"
+		if len(m.Collectors) != 0 {
+			s := make([]string, 0, len(m.Collectors))
+			for _, c := range m.Collectors {
+				s = append(s, fmt.Sprintf("`%s` (%s)", c.Name, c.Versions))
+			}
+			collectors = strings.Join(s, ", ")
+		}
+		_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+			m.Name, m.Type, escapeMarkdown(strings.TrimSpace(m.Help)), strings.Join(m.Labels, ", "), collectors)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CheckReport is the result of Exporter.Check.
+type CheckReport struct {
+	URL         string           `json:"url"`
+	Reachable   CheckResult      `json:"reachable"`
+	TLS         *CheckResult     `json:"tls,omitempty"` // nil for http
+	Auth        CheckResult      `json:"auth"`
+	Discovery   CheckResult      `json:"discovery"` // version and permissions
+	Version     string           `json:"version,omitempty"`
+	SysAdmin    bool             `json:"sysadmin"`
+	Permissions []Permission     `json:"permissions,omitempty"`
+	Collectors  []CollectorCheck `json:"collectors"`
+	OK          bool             `json:"ok"`
+}
+
+type CheckResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CollectorCheck is the result of running a scraper once.
+type CollectorCheck struct {
+	Name       string  `json:"name"`
+	OK         bool    `json:"ok"`
+	Skipped    string  `json:"skipped,omitempty"` // reason of skipping
+	Error      string  `json:"error,omitempty"`
+	Metrics    int     `json:"metrics"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+func newCheckResult(err error) CheckResult {
+	if err != nil {
+		return CheckResult{Error: err.Error()}
+	}
+	return CheckResult{OK: true}
+}
+
+// Check validates the connectivity, credentials and permissions,
+// then runs every scraper once one by one and reports the results.
+// The credentials in the errors are redacted.
+func (e *Exporter) Check() *CheckReport {
+	r := e.check()
+
+	redactor := e.client.Opts.Redactor()
+	r.URL = redactor.Redact(r.URL)
+	for _, res := range []*CheckResult{&r.Reachable, r.TLS, &r.Auth, &r.Discovery} {
+		if res != nil {
+			res.Error = redactor.Redact(res.Error)
+		}
+	}
+	for i := range r.Collectors {
+		r.Collectors[i].Error = redactor.Redact(r.Collectors[i].Error)
+	}
+	return r
+}
+
+func (e *Exporter) check() *CheckReport {
+	r := &CheckReport{URL: e.client.Opts.Url}
+
+	_, err := e.client.Ping()
+	r.Reachable = newCheckResult(err)
+	if u, _ := url.Parse(e.client.Opts.Url); u != nil && u.Scheme == "https" {
+		var tlsErr error
+		if PingFailureReason(err) == PingReasonTLS {
+			tlsErr = err
+		}
+		// the handshake never happened for the network errors
+		if PingFailureReason(err) != PingReasonNetwork {
+			tls := newCheckResult(tlsErr)
+			r.TLS = &tls
+		}
+	}
+	if err != nil {
+		r.Auth = CheckResult{Error: "harbor is unreachable"}
+		return r
+	}
+
+	_, err = e.client.CheckAuth()
+	r.Auth = newCheckResult(err)
+
+	r.Discovery = newCheckResult(e.Discover())
+	e.client.version.mu.RLock()
+	r.Version = e.client.version.raw
+	e.client.version.mu.RUnlock()
+	e.client.access.mu.RLock()
+	r.SysAdmin = e.client.access.sysAdmin
+	r.Permissions = e.client.access.permissions
+	e.client.access.mu.RUnlock()
+
+	r.OK = r.Auth.OK && r.Discovery.OK
+	for _, scraper := range e.scrapers {
+		c := e.checkScraper(scraper)
+		if !c.OK && c.Skipped == "" {
+			r.OK = false
+		}
+		r.Collectors = append(r.Collectors, c)
+	}
+
+	return r
+}
+
+func (e *Exporter) checkScraper(scraper Scraper) CollectorCheck {
+	scraper, reason, detail := e.resolve(scraper)
+	c := CollectorCheck{Name: scraper.Name()}
+	if reason != "" {
+		c.Skipped, c.Error = reason, detail
+		return c
+	}
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+			c.Metrics++
+		}
+		close(done)
+	}()
+
+	start := time.Now()
+	err := scraper.Scrape(e.client, ch)
+	c.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	close(ch)
+	<-done
+
+	if err != nil {
+		c.Error = err.Error()
+	} else {
+		c.OK = true
+	}
+	return c
+}
+
+// WriteText writes the human-readable report.
+func (r *CheckReport) WriteText(w io.Writer) {
+	status := func(c CheckResult) string {
+		if c.OK {
+			return "OK"
+		}
+		return "FAIL: " + c.Error
+	}
+
+	fmt.Fprintf(w, "harbor:      %s\n", r.URL)
+	fmt.Fprintf(w, "reachable:   %s\n", status(r.Reachable))
+	if r.TLS != nil {
+		fmt.Fprintf(w, "tls:         %s\n", status(*r.TLS))
+	}
+	fmt.Fprintf(w, "auth:        %s\n", status(r.Auth))
+	if r.Reachable.OK {
+		fmt.Fprintf(w, "discovery:   %s\n", status(r.Discovery))
+	}
+	if r.Version != "" {
+		fmt.Fprintf(w, "version:     %s\n", r.Version)
+	}
+	if r.Auth.OK {
+		fmt.Fprintf(w, "sysadmin:    %t\n", r.SysAdmin)
+		if len(r.Permissions) != 0 {
+			fmt.Fprintf(w, "permissions: %s\n", formatPermissions(r.Permissions))
+		}
+	}
+
+	if len(r.Collectors) != 0 {
+		fmt.Fprintln(w, "collectors:")
+	}
+	for _, c := range r.Collectors {
+		var s string
+		switch {
+		case c.Skipped != "":
+			s = fmt.Sprintf("SKIP (%s): %s", c.Skipped, c.Error)
+		case c.OK:
+			s = fmt.Sprintf("OK (%d metrics, %.1fms)", c.Metrics, c.DurationMs)
+		default:
+			s = "FAIL: " + c.Error
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", c.Name, s)
+	}
+
+	result := "PASS"
+	if !r.OK {
+		result = "FAIL"
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	fmt.Fprintln(w, result)
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// conditionalCache keeps the validators (ETag and Last-Modified) and the bodies of the responses of harbor,
+// the requests send them by If-None-Match and If-Modified-Since, and the body is reused when harbor
+// answers 304, so the large lists are not downloaded again when they are unchanged.
+// The bodies are evicted least recently used first to stay within the budget.
+type conditionalCache struct {
+	budget int64 // bytes of the bodies, 0 disables the cache
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *conditionalEntry, the most recently used first
+	entries map[string]*list.Element
+
+	notModified uint64
+	bytesSaved  uint64
+}
+
+type conditionalEntry struct {
+	endpoint     string
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+func newConditionalCache(budget int64) *conditionalCache {
+	return &conditionalCache{budget: budget, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// header returns the conditional headers of the entry.
+func (e *conditionalEntry) header() http.Header {
+	h := http.Header{}
+	if e.etag != "" {
+		h.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		h.Set("If-Modified-Since", e.lastModified)
+	}
+	return h
+}
+
+// get returns the entry of the endpoint, nil if there is none.
+func (c *conditionalCache) get(endpoint string) *conditionalEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[endpoint]
+	if !ok {
+		return nil
+	}
+	return el.Value.(*conditionalEntry)
+}
+
+// reuse records the body of the entry was reused after a 304.
+func (c *conditionalCache) reuse(e *conditionalEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.endpoint]; ok && el.Value == e {
+		c.lru.MoveToFront(el)
+	}
+	c.notModified++
+	c.bytesSaved += uint64(len(e.body))
+}
+
+// store keeps the body of a 200 response with its validators, the response without any is not kept.
+func (c *conditionalCache) store(endpoint string, header http.Header, body []byte) {
+	if c.budget <= 0 {
+		return
+	}
+	e := &conditionalEntry{
+		endpoint:     endpoint,
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+		body:         body,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[endpoint]; ok {
+		c.remove(el)
+	}
+	if (e.etag == "" && e.lastModified == "") || int64(len(body)) > c.budget {
+		return
+	}
+
+	c.entries[endpoint] = c.lru.PushFront(e)
+	c.size += int64(len(body))
+	for c.size > c.budget {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *conditionalCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*conditionalEntry)
+	delete(c.entries, e.endpoint)
+	c.size -= int64(len(e.body))
+}
+
+func (c *conditionalCache) collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(notModifiedMetric.Desc(), prometheus.CounterValue, float64(c.notModified))
+	ch <- prometheus.MustNewConstMetric(bytesSavedMetric.Desc(), prometheus.CounterValue, float64(c.bytesSaved))
+	ch <- prometheus.MustNewConstMetric(conditionalCacheBytesMetric.Desc(), prometheus.GaugeValue, float64(c.size))
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// keys returns the endpoints of the cache, the most recently used first.
+func (c *conditionalCache) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*conditionalEntry).endpoint)
+	}
+	return keys
+}
+
+func etag(v string) http.Header {
+	return http.Header{"Etag": []string{v}}
+}
+
+func TestConditionalCacheBudget(t *testing.T) {
+	c := newConditionalCache(10)
+
+	c.store("/a", etag(`"a"`), []byte("aaaa"))
+	c.store("/b", etag(`"b"`), []byte("bbbb"))
+	if got, want := c.keys(), []string{"/b", "/a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys %v, want %v", got, want)
+	}
+
+	// /a is used, so /b is the least recently used one
+	c.reuse(c.get("/a"))
+	c.store("/c", etag(`"c"`), []byte("cccc"))
+	if got, want := c.keys(), []string{"/c", "/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys %v, want %v", got, want)
+	}
+	if c.size != 8 {
+		t.Errorf("size %d, want 8", c.size)
+	}
+
+	// a body over the budget is not kept and doesn't evict the others
+	c.store("/d", etag(`"d"`), []byte("ddddddddddd"))
+	if got, want := c.keys(), []string{"/c", "/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys %v, want %v", got, want)
+	}
+
+	// a response without validators is not kept, and drops the previous one
+	c.store("/a", http.Header{}, []byte("a"))
+	if got, want := c.keys(), []string{"/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys %v, want %v", got, want)
+	}
+	if c.size != 4 {
+		t.Errorf("size %d, want 4", c.size)
+	}
+
+	disabled := newConditionalCache(0)
+	disabled.store("/a", etag(`"a"`), []byte("a"))
+	if disabled.get("/a") != nil {
+		t.Errorf("the disabled cache keeps the body")
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	var mu sync.Mutex
+	version, body := `"v1"`, "[1]"
+	var conditionals []string
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		conditionals = append(conditionals, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == version {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", version)
+		io.WriteString(w, body)
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	client := newTestClient(t, opts)
+
+	probe := func() (string, float64) {
+		t.Helper()
+		var got []byte
+		ch := make(chan prometheus.Metric, 10)
+		err := client.probe(ch, "projects", "/projects", func() (err error) {
+			got, err = client.request("/projects")
+			return err
+		})
+		close(ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for m := range ch {
+			if m.Desc() == probeStatusMetric.Desc() {
+				var pb dto.Metric
+				if err := m.Write(&pb); err != nil {
+					t.Fatal(err)
+				}
+				return string(got), pb.GetGauge().GetValue()
+			}
+		}
+		t.Fatal("no status code reported")
+		return "", 0
+	}
+
+	if got, code := probe(); got != "[1]" || code != 200 {
+		t.Errorf("first request: %s %v, want [1] 200", got, code)
+	}
+	// 304, the kept body is reused and reported as 200
+	if got, code := probe(); got != "[1]" || code != 200 {
+		t.Errorf("not modified: %s %v, want [1] 200", got, code)
+	}
+	if n, saved := client.conditional.notModified, client.conditional.bytesSaved; n != 1 || saved != 3 {
+		t.Errorf("not modified %d, bytes saved %d, want 1 and 3", n, saved)
+	}
+
+	// a new 200 replaces the kept body and validator
+	mu.Lock()
+	version, body = `"v2"`, "[1,2]"
+	mu.Unlock()
+	if got, code := probe(); got != "[1,2]" || code != 200 {
+		t.Errorf("modified: %s %v, want [1,2] 200", got, code)
+	}
+	if got, _ := probe(); got != "[1,2]" {
+		t.Errorf("not modified after the change: %s, want [1,2]", got)
+	}
+	if e := client.conditional.get("/projects"); e == nil || e.etag != `"v2"` || string(e.body) != "[1,2]" {
+		t.Errorf("kept entry %+v, want the v2 one", e)
+	}
+	if client.conditional.size != 5 {
+		t.Errorf("size %d, want 5", client.conditional.size)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"", `"v1"`, `"v1"`, `"v2"`}; !reflect.DeepEqual(conditionals, want) {
+		t.Errorf("If-None-Match sent %q, want %q", conditionals, want)
+	}
+}
@@ -1,10 +1,7 @@
 package collector
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"os"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	//"github.com/prometheus/client_golang/prometheus"
 )
@@ -30,23 +28,31 @@ func Name() string {
 // Verify if Exporter implements prometheus.Collector
 var _ prometheus.Collector = (*Exporter)(nil)
 
-// Metric descriptors.
-var (
-	scrapeDurationDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, exporter, "collector_duration_seconds"),
-		"Collector time duration.",
-		[]string{"collector"}, nil,
-	)
-)
-
 type Exporter struct {
 	//ctx      context.Context  //http timeout will work, don't need this
 	client   *HarborClient
 	scrapers []Scraper
 	metrics  Metrics
+	instance string
 }
 
+// New creates the Exporter from the opts filled by the flags.
 func New(opts *HarborOpts, metrics Metrics, scrapers []Scraper) (*Exporter, error) {
+	return NewExporter(WithHarborOpts(opts), WithMetrics(metrics), WithScrapers(scrapers...))
+}
+
+// NewExporter creates the Exporter, it doesn't touch any global state
+// so it could be embedded in other programs.
+func NewExporter(options ...Option) (*Exporter, error) {
+	c := &config{opts: DefaultHarborOpts()}
+	for _, o := range options {
+		o(c)
+	}
+	opts := c.opts.clone()
+	for _, set := range c.fields {
+		set(opts)
+	}
+
 	uri := opts.Url
 	if !strings.Contains(uri, "://") {
 		uri = "http://" + uri
@@ -59,54 +65,163 @@ func New(opts *HarborOpts, metrics Metrics, scrapers []Scraper) (*Exporter, erro
 		return nil, fmt.Errorf("invalid harbor URL: %s", uri)
 	}
 
-	rootCAs, err := x509.SystemCertPool()
-	if err != nil {
+	switch opts.PingStrategy {
+	case "":
+		opts.PingStrategy = PingStrategyAuto
+	case PingStrategyAuto, PingStrategySystemInfo, PingStrategyConfigurations:
+	default:
+		return nil, fmt.Errorf("invalid ping strategy: %s", opts.PingStrategy)
+	}
+
+	if err := opts.validateLimits(); err != nil {
 		return nil, err
 	}
+	if opts.CacheTTL < 0 {
+		return nil, fmt.Errorf("invalid cache TTL: %s", opts.CacheTTL)
+	}
+	if opts.ConditionalCacheBytes < 0 {
+		return nil, fmt.Errorf("invalid conditional cache bytes: %d", opts.ConditionalCacheBytes)
+	}
+
+	if c.logger == nil {
+		c.logger = log.StandardLogger()
+	}
 
-	tlsClientConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		RootCAs:    rootCAs,
+	if opts.header, err = parseHeaders(opts.Headers); err != nil {
+		return nil, err
 	}
 
-	if opts.Insecure {
-		tlsClientConfig.InsecureSkipVerify = true
+	if err := opts.initSecrets(c.logger, c.auth == nil); err != nil {
+		return nil, err
 	}
 
-	user := os.Getenv("HARBOR_USERNAME")
-	if user != "" {
-		opts.Username = user
+	client := c.httpClient
+	if client == nil {
+		newTransport, err := opts.transportFactory()
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection options: %s", err)
+		}
+		transport, err := newReloadingTransport(opts, c.logger, newTransport)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS options: %s", err)
+		}
+
+		client = &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: transport,
+		}
 	}
 
-	pass := os.Getenv("HARBOR_PASSWORD")
-	if pass != "" {
-		opts.password = pass
+	if c.metrics == nil {
+		metrics := NewMetrics()
+		c.metrics = &metrics
+	}
+	if c.scrapers == nil {
+		c.scrapers = DefaultRegistry().Defaults()
+	}
+	if c.tracer == nil {
+		c.tracer = noopTracer{}
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: tlsClientConfig,
+	limiters := []*Limiter{newLimiter(limiterTarget, opts.RateLimit, opts.RateBurst, opts.MaxInFlight)}
+	if c.limiter != nil {
+		limiters = append(limiters, c.limiter)
 	}
 
 	hc := &HarborClient{
-		Opts: opts,
-		Client: &http.Client{
-			Timeout:   opts.Timeout,
-			Transport: transport,
-		},
+		Opts:     opts,
+		Client:   client,
+		access:   &accessInfo{},
+		version:  &versionInfo{},
+		auth:     c.auth,
+		limiters: limiters,
+		tracer:   c.tracer,
+		logger:   c.logger,
+
+		cacheStats:  &cacheStats{},
+		conditional: newConditionalCache(opts.ConditionalCacheBytes),
+		config:      &configState{},
+	}
+	if opts.ResponseCache && opts.CacheTTL > 0 {
+		hc.cache = newResponseCache(opts.CacheTTL, hc.cacheStats)
+	}
+	if hc.auth == nil {
+		// the session login goes through the limiters too
+		var err error
+		if hc.auth, err = newAuthenticator(opts, hc.doLimited, c.logger); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Exporter{
 		client:   hc,
-		metrics:  metrics,
-		scrapers: scrapers,
+		metrics:  *c.metrics,
+		scrapers: c.scrapers,
+		instance: u.Host,
 	}, nil
 }
 
+// Filter returns an Exporter which only runs the scrapers of e named in names,
+// it shares the client and the metrics with e, e.g. for the collect[] params of a scrape.
+// The names of the scrapers not enabled in e are ignored, no names means all of them.
+func (e *Exporter) Filter(names []string) *Exporter {
+	if len(names) == 0 {
+		return e
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	f := *e
+	f.scrapers = make([]Scraper, 0, len(names))
+	for _, scraper := range e.scrapers {
+		if wanted[scraper.Name()] {
+			f.scrapers = append(f.scrapers, scraper)
+			delete(wanted, scraper.Name())
+		}
+	}
+	for name := range wanted {
+		e.client.log().WithField("scraper", name).Debug("ignore the scraper which is not enabled")
+	}
+	return &f
+}
+
+// Instance is the host of the harbor, e.g. the grouping key of the pushed metrics.
+func (e *Exporter) Instance() string {
+	return e.instance
+}
+
+// Discover detects the harbor version and what the harbor user could access right now,
+// the collectors which can't run are skipped in the following scrapes.
+func (e *Exporter) Discover() error {
+	if err := e.client.refreshVersion(true); err != nil {
+		return err
+	}
+	return e.client.refreshAccess(true)
+}
+
+// Describe implements prometheus.Collector, it describes every metric the Exporter could expose,
+// or nothing (an unchecked collector) if a scraper doesn't declare its metrics.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- e.metrics.TotalScrapes.Desc()
-	ch <- e.metrics.Error.Desc()
-	e.metrics.ScrapeErrors.Describe(ch)
-	ch <- e.metrics.HarborUp.Desc()
+	specs := exporterMetrics()
+	for _, scraper := range e.scrapers {
+		ms, ok := scraper.(MetricScraper)
+		if !ok {
+			e.client.log().WithField("scraper", scraper.Name()).Debug("the metrics are not declared, the exporter is unchecked")
+			return
+		}
+		specs = append(specs, ms.Metrics()...)
+	}
+
+	seen := make(map[*MetricSpec]bool, len(specs))
+	for _, s := range specs {
+		if !seen[s] {
+			seen[s] = true
+			ch <- s.Desc()
+		}
+	}
 }
 
 // Collect implements prometheus.Collector.
@@ -117,6 +232,14 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- e.metrics.Error
 	e.metrics.ScrapeErrors.Collect(ch)
 	ch <- e.metrics.HarborUp
+	if atomic.LoadUint32(e.metrics.authChecked) == 1 {
+		ch <- e.metrics.AuthValid
+	}
+	e.metrics.PingFailureReason.Collect(ch)
+	// the global limiter is shared by the Exporters, it is collected by itself
+	e.client.limiters[0].collect(ch)
+	e.client.cacheStats.collect(ch)
+	e.client.conditional.collect(ch)
 }
 
 func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
@@ -124,72 +247,144 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 
 	scrapeTime := time.Now()
 
-	if pong, err := e.client.Ping(); pong != true || err != nil {
-		log.WithFields(log.Fields{
-			"url":      e.client.Opts.Url+"/configurations",
-			"username": e.client.Opts.Username,
-		}).Error(err)
+	span := e.client.startSpan("scrape")
+	defer span.End()
+	client := e.client.withSpan(span).withScrapeCache()
+
+	pong, err := client.Ping()
+	if !pong || err != nil {
+		client.log().WithField("reason", PingFailureReason(err)).Error(err)
+		e.setPingFailureReason(err)
 		e.metrics.HarborUp.Set(0)
+		// the credentials can't be checked, harbor_auth_valid keeps the last result
 		e.metrics.Error.Set(1)
+		ch <- prometheus.MustNewConstMetric(scrapeDurationMetric.Desc(), prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), "reach")
+		// every scraper depends on a reachable harbor, don't flood it and the log
+		client.log().Debugf("harbor is down, skip %d scrapers", len(e.scrapers))
+		return
 	}
 	e.metrics.HarborUp.Set(1)
 	e.metrics.Error.Set(0)
 
-	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), "reach")
+	if err := client.refreshVersion(false); err != nil {
+		client.log().WithField("url", systemInfoUrl).Warn(err)
+	}
+
+	valid, err := client.CheckAuth()
+	e.setPingFailureReason(err)
+	if !valid || err != nil {
+		client.log().WithFields(log.Fields{
+			"username": client.Opts.Username,
+			"reason":   PingFailureReason(err),
+		}).Error(err)
+		e.metrics.Error.Set(1)
+		if PingFailureReason(err) == PingReasonAuth {
+			e.metrics.setAuthValid(0)
+			ch <- prometheus.MustNewConstMetric(scrapeDurationMetric.Desc(), prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), "reach")
+			// every scraper would be refused as well, don't flood harbor and the log
+			client.log().Debugf("the credentials are refused, skip %d scrapers", len(e.scrapers))
+			return
+		}
+		// harbor failed to answer the check, harbor_auth_valid keeps the last result
+	} else {
+		e.metrics.setAuthValid(1)
+		if err := client.refreshAccess(false); err != nil {
+			client.log().WithField("url", usersUrl+"/current").Warn(err)
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeDurationMetric.Desc(), prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), "reach")
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
 	for _, scraper := range e.scrapers {
+		scraper, reason, detail := e.resolve(scraper)
+		if reason != "" {
+			client.log().WithField("scraper", scraper.Name()).Debugf("skipped, %s", detail)
+			ch <- prometheus.MustNewConstMetric(collectorSkippedMetric.Desc(), prometheus.GaugeValue, 1, scraper.Name(), reason)
+			continue
+		}
 
 		wg.Add(1)
 		go func(scraper Scraper) {
 			defer wg.Done()
 			label := scraper.Name()
 			scrapeTime := time.Now()
-			if err := scraper.Scrape(e.client, ch); err != nil {
-				log.WithField("scraper", scraper.Name()).Error(err)
+			span := client.startSpan("scrape " + label)
+			span.SetAttribute("collector", label)
+			err := scraper.Scrape(client.withSpan(span), ch)
+			span.SetError(err)
+			span.End()
+			if err != nil {
+				client.log().WithField("scraper", scraper.Name()).Error(err)
 				e.metrics.ScrapeErrors.WithLabelValues(label).Inc()
 				e.metrics.Error.Set(1)
 			}
-			ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
+			ch <- prometheus.MustNewConstMetric(scrapeDurationMetric.Desc(), prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
 		}(scraper)
 	}
 }
 
+func (e *Exporter) setPingFailureReason(err error) {
+	reason := PingFailureReason(err)
+	for _, r := range pingReasons {
+		var v float64
+		if r == reason {
+			v = 1
+		}
+		e.metrics.PingFailureReason.WithLabelValues(r).Set(v)
+	}
+}
+
+// resolve returns the implementation of the scraper for the harbor version,
+// or the reason and the detail why it should be skipped.
+func (e *Exporter) resolve(scraper Scraper) (Scraper, string, string) {
+	if v, known := e.client.harborVersion(); known {
+		if vs, ok := scraper.(VersionedScraper); ok {
+			if ok, detail := vs.SupportedVersions().Supports(v); !ok {
+				return scraper, SkipReasonVersion, detail
+			}
+		}
+		if vs, ok := scraper.(VersionSwitcher); ok {
+			scraper = vs.ForVersion(v)
+		}
+	}
+
+	if missing := e.client.missingPermissions(scraper); len(missing) != 0 {
+		return scraper, SkipReasonPermission, "user lacks permissions " + formatPermissions(missing)
+	}
+
+	return scraper, "", ""
+}
+
 // Metrics represents exporter metrics which values can be carried between http requests.
 type Metrics struct {
 	TotalScrapes prometheus.Counter
 	ScrapeErrors *prometheus.CounterVec
 	Error        prometheus.Gauge
 	HarborUp     prometheus.Gauge
+
+	AuthValid         prometheus.Gauge
+	PingFailureReason *prometheus.GaugeVec
+
+	authChecked *uint32 // 1 once the credentials were checked, AuthValid is not exposed before
 }
 
 // NewMetrics creates new Metrics instance.
 func NewMetrics() Metrics {
-	subsystem := exporter
 	return Metrics{
-		TotalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: subsystem,
-			Name:      "scrapes_total",
-			Help:      "Total number of times harbor was scraped for metrics.",
-		}),
-		ScrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
-			Namespace: namespace,
-			Subsystem: subsystem,
-			Name:      "scrape_errors_total",
-			Help:      "Total number of times an error occurred scraping a harbor.",
-		}, []string{"collector"}),
-		Error: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Subsystem: subsystem,
-			Name:      "last_scrape_error",
-			Help:      "Whether the last scrape of metrics from harbor resulted in an error (1 for error, 0 for success).",
-		}),
-		HarborUp: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "up",
-			Help:      "Whether the harbor is up.",
-		}),
+		TotalScrapes:      prometheus.NewCounter(totalScrapesMetric.counterOpts()),
+		ScrapeErrors:      prometheus.NewCounterVec(scrapeErrorsMetric.counterOpts(), scrapeErrorsMetric.Labels),
+		Error:             prometheus.NewGauge(lastScrapeErrorMetric.gaugeOpts()),
+		HarborUp:          prometheus.NewGauge(upMetric.gaugeOpts()),
+		AuthValid:         prometheus.NewGauge(authValidMetric.gaugeOpts()),
+		PingFailureReason: prometheus.NewGaugeVec(pingFailureReasonMetric.gaugeOpts(), pingFailureReasonMetric.Labels),
+		authChecked:       new(uint32),
 	}
 }
+
+// setAuthValid sets the result of the credential check, AuthValid is exposed from then on.
+func (m Metrics) setAuthValid(v float64) {
+	m.AuthValid.Set(v)
+	atomic.StoreUint32(m.authChecked, 1)
+}
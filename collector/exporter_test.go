@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScrapeAuth(t *testing.T) {
+	var (
+		pingStatus, authStatus int32 = 200, 200
+		statistics             int32
+	)
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/systeminfo":
+			w.WriteHeader(int(atomic.LoadInt32(&pingStatus)))
+			io.WriteString(w, `{"harbor_version":"v1.10.3"}`)
+		case "/api/users/current":
+			w.WriteHeader(int(atomic.LoadInt32(&authStatus)))
+			io.WriteString(w, `{"user_id":1,"has_admin_role":true}`)
+		case "/api/statistics":
+			atomic.AddInt32(&statistics, 1)
+			io.WriteString(w, `{"total_project_count":1}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.PingStrategy = PingStrategySystemInfo
+	opts.AllowDefaultPassword = true
+	metrics := NewMetrics()
+	e, err := NewExporter(WithHarborOpts(opts), WithMetrics(metrics), WithScrapers(ScrapeStatistics{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	// harbor is down before the credentials are checked once, they are neither valid nor refused
+	atomic.StoreInt32(&pingStatus, 500)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == authValidMetric.Name {
+			t.Errorf("%s is exposed before the credentials are checked", authValidMetric.Name)
+		}
+	}
+
+	for _, c := range []struct {
+		name             string
+		ping, auth       int32
+		up, valid, error float64
+		scraped          bool
+	}{
+		{"valid", 200, 200, 1, 1, 0, true},
+		// the credentials can't be checked, the last result is kept
+		{"harbor down", 500, 200, 0, 1, 1, false},
+		{"check failed", 200, 500, 1, 1, 1, true},
+		{"refused", 200, 401, 1, 0, 1, false},
+		{"refused and harbor down", 500, 401, 0, 0, 1, false},
+	} {
+		atomic.StoreInt32(&pingStatus, c.ping)
+		atomic.StoreInt32(&authStatus, c.auth)
+		before := atomic.LoadInt32(&statistics)
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+		up, valid, lastErr := testutil.ToFloat64(metrics.HarborUp), testutil.ToFloat64(metrics.AuthValid), testutil.ToFloat64(metrics.Error)
+		if up != c.up || valid != c.valid || lastErr != c.error {
+			t.Errorf("%s: up, auth valid and error are %v %v %v, want %v %v %v", c.name, up, valid, lastErr, c.up, c.valid, c.error)
+		}
+		if scraped := atomic.LoadInt32(&statistics) != before; scraped != c.scraped {
+			t.Errorf("%s: scraped %v, want %v", c.name, scraped, c.scraped)
+		}
+	}
+}
@@ -1,29 +1,17 @@
 package collector
 
 import (
-	"fmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 )
 
 var (
-	Scrapers = map[Scraper]bool{
-		ScrapeSystemInfo{}:  true,
-		ScrapeStatistics{}:  true,
-		ScrapeQuotas{}:      true,
-		ScrapeHealth{}:      true,
-		ScrapeProjects{}:    true,
-		ScrapeUsers{}:       true,
-		ScrapeLogs{}:        true,
-		ScrapeReplication{}: false,
-		ScrapeGc{}:          false,
-		ScrapeRegistries{}: false,
-	}
-
 	// TODO
 	//  tags always return full tag, see https://github.com/goharbor/harbor/issues/12279
 
@@ -37,59 +25,227 @@ type HarborOpts struct {
 	UA       string
 	Timeout  time.Duration
 	Insecure bool
+
+	// PasswordFile is read instead of the password and reread when it changes, e.g. a kubernetes secret
+	PasswordFile string
+	// AllowDefaultPassword starts with the default password "password" of --harbor-pass, refused otherwise
+	AllowDefaultPassword bool
+
+	passwordFlag *flag.Flag // --harbor-pass of AddFlags, nil for the opts not filled by the flags
+	passwordSet  bool       // by SetPassword or WithBasicAuth
+
+	passwordFile *secretFile
+	redactor     *Redactor
+
+	// AuthMode is one of basic, robot, bearer, oidc-cli-secret and session
+	AuthMode  string
+	TokenFile string // bearer token of the bearer auth mode
+
+	// TLS to harbor, the files are reloaded when they change
+	TLSCAFile     string // appended to the system pool
+	TLSCADir      string // every certificate in it is appended to the system pool
+	TLSCertFile   string // client certificate for mTLS
+	TLSKeyFile    string
+	TLSServerName string // verify the certificate of harbor by it instead of the host of Url
+	TLSMinVersion string // TLS10, TLS11, TLS12 or TLS13
+
+	// ProxyURL is the http, https, socks5 or socks5h proxy to harbor, "env" for HTTP_PROXY and HTTPS_PROXY
+	ProxyURL string
+	Headers  []string // key=value headers sent to harbor, e.g. for the auth gateways
+	Resolve  []string // host[:port]=addr[:port] dialed instead of resolving the host
+
+	header http.Header
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 is no limit
+	IdleConnTimeout     time.Duration
+	HTTP2               bool
+
+	// limits of the requests to this harbor, 0 for no limit
+	RateLimit   float64 // requests per second
+	RateBurst   int     // default the rate limit rounded up
+	MaxInFlight int
+
+	// ResponseCache sends the identical requests once and keeps the responses for the scrape,
+	// or for CacheTTL across the scrapes
+	ResponseCache bool
+	CacheTTL      time.Duration
+
+	// ConditionalCacheBytes is the budget of the bodies kept for the conditional requests, 0 disables them
+	ConditionalCacheBytes int64
+
+	// PingStrategy is one of auto, systeminfo and configurations
+	PingStrategy string
+
+	PermissionRefreshInterval time.Duration
+
+	// keep the deprecated harbor_ref_work_<area> metrics beside harbor_api_probe_*
+	RefWorkMetrics bool
+
+	// OverrideVersion replaces the harbor_version of /systeminfo,
+	// some versions don't contain the version number
+	OverrideVersion string
 }
 
-type HarborClient struct {
-	Client *http.Client
-	Opts   *HarborOpts
+// DefaultHarborOpts returns the opts with the defaults of the flags.
+func DefaultHarborOpts() *HarborOpts {
+	return &HarborOpts{
+		Username:                  "admin",
+		password:                  defaultPassword,
+		UA:                        "harbor_exporter",
+		Timeout:                   time.Millisecond * 1600,
+		TLSMinVersion:             "TLS12",
+		MaxIdleConns:              100,
+		MaxIdleConnsPerHost:       2,
+		IdleConnTimeout:           90 * time.Second,
+		AuthMode:                  AuthModeBasic,
+		PingStrategy:              PingStrategyAuto,
+		PermissionRefreshInterval: 5 * time.Minute,
+		RefWorkMetrics:            true,
+		ResponseCache:             true,
+		ConditionalCacheBytes:     16 << 20,
+		redactor:                  &Redactor{},
+	}
 }
 
-// could use for member and repos
-type subInsJson struct {
-	Id        int `json:"id"`
-	ProjectID int `json:"project_id"`
+// clone copies the opts for an Exporter, so NewExporter never changes the opts of the caller.
+// The copy shares the Redactor, the logger hooked by the caller redacts the secrets of the Exporter.
+func (o *HarborOpts) clone() *HarborOpts {
+	o.Redactor()
+	c := *o
+	c.Headers = append([]string(nil), o.Headers...)
+	c.Resolve = append([]string(nil), o.Resolve...)
+	c.passwordFile = nil
+	c.header = nil
+	return &c
 }
 
-type idJson struct {
-	ID int `json:"id"`
+// SetPassword sets the password of the harbor user.
+func (o *HarborOpts) SetPassword(password string) {
+	o.password = password
+	o.passwordSet = true
 }
 
-type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+type HarborClient struct {
+	Client *http.Client
+	Opts   *HarborOpts
+
+	access  *accessInfo
+	version *versionInfo
+
+	auth Authenticator
+
+	limiters []*Limiter // the target one, then the global one
+
+	cache      *responseCache // nil for no cache, set for every scrape unless it has a TTL
+	cacheStats *cacheStats
+
+	conditional *conditionalCache // validators and bodies for the conditional requests
+
+	config *configState // last seen hash of /configurations
+
+	tracer Tracer
+	span   Span // parent of the request spans
+
+	logger log.FieldLogger
 }
 
-// use after set Opts
+// AddFlag adds the flags to the global flag set.
 func (o *HarborOpts) AddFlag() {
-	flag.StringVar(&o.Url, "harbor-server", "", "HTTP API address of a harbor server or agent. (prefix with https:// to connect over HTTPS)")
-	flag.StringVar(&o.Username, "harbor-user", "admin", "harbor username")
-	flag.StringVar(&o.password, "harbor-pass", "password", "harbor password")
-	flag.StringVar(&o.UA, "harbor-ua", "harbor_exporter", "user agent of the harbor http client")
-	flag.DurationVar(&o.Timeout, "time-out", time.Millisecond*1600, "Timeout on HTTP requests to the harbor API.")
-	flag.BoolVar(&o.Insecure, "insecure", false, "Disable TLS host verification.")
+	o.AddFlags(flag.CommandLine)
+}
+
+// AddFlags adds the flags to fs, the defaults are the ones of DefaultHarborOpts.
+func (o *HarborOpts) AddFlags(fs *flag.FlagSet) {
+	d := DefaultHarborOpts()
+	fs.StringVar(&o.Url, "harbor-server", d.Url, "HTTP API address of a harbor server or agent. (prefix with https:// to connect over HTTPS)")
+	fs.StringVar(&o.Username, "harbor-user", d.Username, "harbor username")
+	fs.StringVar(&o.password, "harbor-pass", d.password, "harbor password, visible in ps, prefer --harbor-pass-file or HARBOR_PASSWORD")
+	o.passwordFlag = fs.Lookup("harbor-pass")
+	fs.StringVar(&o.PasswordFile, "harbor-pass-file", d.PasswordFile, "File containing the harbor password (or the robot secret, the OIDC CLI secret), e.g. a mounted kubernetes secret, reread when it is rotated. Overrides --harbor-pass.")
+	fs.BoolVar(&o.AllowDefaultPassword, "allow-default-password", d.AllowDefaultPassword, "Allow starting with the default password \"password\", refused otherwise.")
+	fs.StringVar(&o.AuthMode, "harbor-auth-mode", d.AuthMode, "How to authenticate to harbor: [basic, robot, bearer, oidc-cli-secret, session]. robot adds the robot$ prefix to --harbor-user if missing, oidc-cli-secret sends the CLI secret of the OIDC user as --harbor-pass, bearer sends the token of --harbor-token-file, session logs in by the UI form and sends the session cookie.")
+	fs.StringVar(&o.TokenFile, "harbor-token-file", d.TokenFile, "File containing the bearer token of the bearer auth mode, reread when it is rotated.")
+	fs.StringVar(&o.UA, "harbor-ua", d.UA, "user agent of the harbor http client")
+	fs.DurationVar(&o.Timeout, "time-out", d.Timeout, "Timeout on HTTP requests to the harbor API.")
+	fs.BoolVar(&o.Insecure, "insecure", d.Insecure, "Disable TLS host verification.")
+	fs.StringVar(&o.TLSCAFile, "tls.ca-file", d.TLSCAFile, "CA certificate file to verify harbor, appended to the system pool. Reloaded when it changes.")
+	fs.StringVar(&o.TLSCADir, "tls.ca-dir", d.TLSCADir, "Directory of the CA certificates to verify harbor, appended to the system pool. Reloaded when they change.")
+	fs.StringVar(&o.TLSCertFile, "tls.cert-file", d.TLSCertFile, "Client certificate file for the mTLS to harbor. Reloaded when it changes.")
+	fs.StringVar(&o.TLSKeyFile, "tls.key-file", d.TLSKeyFile, "Client key file for the mTLS to harbor. Reloaded when it changes.")
+	fs.StringVar(&o.TLSServerName, "tls.server-name", d.TLSServerName, "Server name to verify the certificate of harbor and send by SNI, default the host of --harbor-server.")
+	fs.StringVar(&o.TLSMinVersion, "tls.min-version", d.TLSMinVersion, "Minimum TLS version to harbor: [TLS10, TLS11, TLS12, TLS13]")
+	fs.StringVar(&o.ProxyURL, "harbor-proxy-url", d.ProxyURL, "Proxy to harbor: an http://, https://, socks5:// or socks5h:// URL, or \"env\" to use HTTP_PROXY, HTTPS_PROXY and NO_PROXY. Empty connects directly.")
+	fs.StringArrayVar(&o.Headers, "harbor-header", d.Headers, "Extra header sent to harbor as key=value, e.g. for an auth gateway in front of harbor, repeatable.")
+	fs.StringArrayVar(&o.Resolve, "harbor-resolve", d.Resolve, "Dial addr instead of resolving host, as host=addr or host:port=addr:port like curl --resolve, the TLS is still verified by host. Repeatable.")
+	fs.IntVar(&o.MaxIdleConns, "harbor-max-idle-conns", d.MaxIdleConns, "Maximum idle connections to harbor, 0 is no limit.")
+	fs.IntVar(&o.MaxIdleConnsPerHost, "harbor-max-idle-conns-per-host", d.MaxIdleConnsPerHost, "Maximum idle connections per harbor host.")
+	fs.IntVar(&o.MaxConnsPerHost, "harbor-max-conns-per-host", d.MaxConnsPerHost, "Maximum connections per harbor host, 0 is no limit.")
+	fs.DurationVar(&o.IdleConnTimeout, "harbor-idle-conn-timeout", d.IdleConnTimeout, "How long an idle connection to harbor is kept, 0 is forever.")
+	fs.BoolVar(&o.HTTP2, "harbor-http2", d.HTTP2, "Use HTTP/2 to harbor over HTTPS when it supports it.")
+	fs.Float64Var(&o.RateLimit, "harbor-rate-limit", d.RateLimit, "Maximum requests per second to harbor, 0 is no limit. The requests which can't start within --time-out fail.")
+	fs.IntVar(&o.RateBurst, "harbor-rate-burst", d.RateBurst, "Maximum burst of requests to harbor over --harbor-rate-limit, default the rate limit rounded up.")
+	fs.IntVar(&o.MaxInFlight, "harbor-max-in-flight", d.MaxInFlight, "Maximum requests to harbor at the same time, 0 is no limit.")
+	fs.BoolVar(&o.ResponseCache, "harbor-response-cache", d.ResponseCache, "Send the identical requests of a scrape to harbor once and share the responses among the collectors.")
+	fs.DurationVar(&o.CacheTTL, "harbor-cache-ttl", d.CacheTTL, "Keep the responses of harbor for the TTL across the scrapes instead of for a scrape, the metrics are as old as the TTL then. 0 caches for a scrape only.")
+	fs.Int64Var(&o.ConditionalCacheBytes, "harbor-conditional-cache-bytes", d.ConditionalCacheBytes, "Bytes of the responses with an ETag or Last-Modified kept to send the conditional requests to harbor, the kept body is reused when harbor answers 304 Not Modified. 0 disables the conditional requests.")
+	fs.StringVar(&o.PingStrategy, "ping-strategy", d.PingStrategy, "How to check harbor is alive: [auto, systeminfo, configurations], auto tries /ping then /systeminfo, configurations requires the admin user.")
+	fs.DurationVar(&o.PermissionRefreshInterval, "permission-refresh-interval", d.PermissionRefreshInterval, "Interval to rediscover the harbor version and the permissions of the harbor user, the collectors which can't run are skipped.")
+	fs.BoolVar(&o.RefWorkMetrics, "compat.ref-work-metrics", d.RefWorkMetrics, "Also expose the deprecated harbor_ref_work_<area> metrics, only for migrating to harbor_api_probe_success.")
+	fs.StringVar(&o.OverrideVersion, "override-version", d.OverrideVersion, "override the harbor version")
 }
 
 func (h *HarborClient) request(endpoint string) ([]byte, error) {
-	url := h.Opts.Url + endpoint
-	log.Debugf("request url %s", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	if h.cache == nil {
+		return h.fetch(endpoint)
+	}
+	body, hit, err := h.cache.get(endpoint, func() ([]byte, error) {
+		return h.fetch(endpoint)
+	})
+	if hit {
+		h.log().Debugf("request url %s, answered by the cache", h.Opts.Url+endpoint)
 	}
+	return body, err
+}
 
-	req.SetBasicAuth(h.Opts.Username, h.Opts.password)
-	req.Header.Set("User-Agent", h.Opts.UA)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+// fetch sends the request to harbor and returns the body of the 200 response,
+// or the kept body of the 304 response, which the probes report as 200 then.
+func (h *HarborClient) fetch(endpoint string) (_ []byte, err error) {
+	url := h.Opts.Url + endpoint
+	h.log().Debugf("request url %s", url)
+
+	span := h.startSpan("GET " + strings.SplitN(endpoint, "?", 2)[0])
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.url", url)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	var cached *conditionalEntry
+	var header http.Header
+	if h.conditional != nil {
+		if cached = h.conditional.get(endpoint); cached != nil {
+			header = cached.header()
+		}
+	}
 
-	resp, err := h.Client.Do(req)
+	resp, err := h.do(endpoint, true, header)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		h.log().Debugf("request url %s, not modified", url)
+		h.conditional.reuse(cached)
+		return cached.body, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error handling request for %s http-statuscode: %s", endpoint, resp.Status)
+		return nil, &harborclient.StatusError{Endpoint: endpoint, Code: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -97,29 +253,26 @@ func (h *HarborClient) request(endpoint string) ([]byte, error) {
 		return nil, err
 	}
 
+	if h.conditional != nil {
+		h.conditional.store(endpoint, resp.Header, body)
+	}
 	return body, nil
 }
 
-func (h *HarborClient) Ping() (bool, error) {
-	req, err := http.NewRequest("GET", h.Opts.Url+"/configurations", nil)
-	if err != nil {
-		return false, err
-	}
-	req.SetBasicAuth(h.Opts.Username, h.Opts.password)
+// Request implements harborclient.Requester.
+func (h *HarborClient) Request(endpoint string) ([]byte, error) {
+	return h.request(endpoint)
+}
 
-	resp, err := h.Client.Do(req)
-	if err != nil {
-		return false, err
+// log returns the logger of the client, the logrus standard logger for a zero HarborClient.
+func (h *HarborClient) log() log.FieldLogger {
+	if h.logger == nil {
+		return log.StandardLogger()
 	}
+	return h.logger
+}
 
-	resp.Body.Close()
-
-	switch {
-	case resp.StatusCode == http.StatusOK:
-		return true, nil
-	case resp.StatusCode == http.StatusUnauthorized:
-		return false, errors.New("username or password incorrect")
-	default:
-		return false, fmt.Errorf("error handling request, http-statuscode: %s", resp.Status)
-	}
+// api is the typed client of the harbor API whose requests go through the HarborClient.
+func (h *HarborClient) api() *harborclient.Client {
+	return harborclient.New(h)
 }
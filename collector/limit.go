@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// check interface
+var _ prometheus.Collector = (*Limiter)(nil)
+
+// names of the limiters, the limiter label of the throttle metrics
+const (
+	limiterGlobal = "global"
+	limiterTarget = "target"
+)
+
+// Limiter caps the rate and the concurrency of the requests to harbor, the scrapers run at the same time
+// and the enumerating ones send many requests, it keeps the exporter from overloading harbor core and its database.
+// A Limiter given by WithGlobalLimiter is shared by the Exporters of several harbors,
+// register it next to them to expose its throttle metrics once.
+type Limiter struct {
+	name     string
+	rate     *rate.Limiter // nil for no rate limit
+	inFlight chan struct{} // nil for no cap
+
+	throttled uint64 // requests which waited
+	waitNanos int64  // time waited
+}
+
+// NewLimiter returns a Limiter of rps requests per second with bursts of burst requests,
+// and at most maxInFlight requests at the same time, 0 for no limit.
+func NewLimiter(rps float64, burst, maxInFlight int) *Limiter {
+	return newLimiter(limiterGlobal, rps, burst, maxInFlight)
+}
+
+func newLimiter(name string, rps float64, burst, maxInFlight int) *Limiter {
+	l := &Limiter{name: name}
+	if rps > 0 {
+		if burst <= 0 {
+			burst = int(math.Ceil(rps))
+		}
+		l.rate = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// validateLimits checks the per target limits of the opts.
+func (o *HarborOpts) validateLimits() error {
+	if o.RateLimit < 0 || math.IsInf(o.RateLimit, 0) || math.IsNaN(o.RateLimit) {
+		return fmt.Errorf("invalid rate limit: %v", o.RateLimit)
+	}
+	if o.RateBurst < 0 {
+		return fmt.Errorf("invalid rate burst: %d", o.RateBurst)
+	}
+	if o.MaxInFlight < 0 {
+		return fmt.Errorf("invalid max in flight requests: %d", o.MaxInFlight)
+	}
+	return nil
+}
+
+// acquire waits for the turn of a request, release must be called when the request is done.
+func (l *Limiter) acquire(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	waited := false
+
+	if l.rate != nil {
+		r := l.rate.Reserve()
+		if delay := r.Delay(); delay > 0 {
+			waited = true
+			deadline, ok := ctx.Deadline()
+			if ok && time.Until(deadline) < delay {
+				r.Cancel()
+				l.observe(start)
+				return nil, fmt.Errorf("throttled by the %s rate limit, the request would wait %s", l.name, delay)
+			}
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				r.Cancel()
+				l.observe(start)
+				return nil, fmt.Errorf("throttled by the %s rate limit: %s", l.name, ctx.Err())
+			}
+		}
+	}
+
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			waited = true
+			select {
+			case l.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				l.observe(start)
+				return nil, fmt.Errorf("throttled by the %s max in flight requests: %s", l.name, ctx.Err())
+			}
+		}
+	}
+
+	if waited {
+		l.observe(start)
+	}
+	return l.release, nil
+}
+
+func (l *Limiter) release() {
+	if l.inFlight != nil {
+		<-l.inFlight
+	}
+}
+
+func (l *Limiter) observe(start time.Time) {
+	atomic.AddUint64(&l.throttled, 1)
+	atomic.AddInt64(&l.waitNanos, int64(time.Since(start)))
+}
+
+// Describe implements prometheus.Collector, the Limiter is unchecked so it could be registered
+// next to the Exporters, which send the same metrics of their target limiters.
+func (l *Limiter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (l *Limiter) Collect(ch chan<- prometheus.Metric) {
+	l.collect(ch)
+}
+
+// collect sends the throttle metrics of the limiter.
+func (l *Limiter) collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(throttledRequestsMetric.Desc(), prometheus.CounterValue,
+		float64(atomic.LoadUint64(&l.throttled)), l.name)
+	ch <- prometheus.MustNewConstMetric(throttleWaitMetric.Desc(), prometheus.CounterValue,
+		time.Duration(atomic.LoadInt64(&l.waitNanos)).Seconds(), l.name)
+	ch <- prometheus.MustNewConstMetric(inFlightRequestsMetric.Desc(), prometheus.GaugeValue,
+		float64(len(l.inFlight)), l.name)
+}
+
+// limit waits for the limiters of the client, the target one first so a request
+// doesn't hold a global slot while waiting for its harbor.
+func (h *HarborClient) limit() (release func(), err error) {
+	if len(h.limiters) == 0 {
+		return func() {}, nil
+	}
+
+	ctx := context.Background()
+	if h.Opts.Timeout > 0 {
+		// a request which can't start within the timeout would fail anyway
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Opts.Timeout)
+		defer cancel()
+	}
+
+	releases := make([]func(), 0, len(h.limiters))
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, l := range h.limiters {
+		r, err := l.acquire(ctx)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+// releaseBody releases the limiters when the body of the response is closed,
+// the request is in flight until it is read.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// doLimited sends the request when the limiters allow it.
+func (h *HarborClient) doLimited(req *http.Request) (*http.Response, error) {
+	release, err := h.limit()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
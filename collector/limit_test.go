@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGlobalLimiterCollectedOnce(t *testing.T) {
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"harbor_version":"v1.10.3","registry_url":"harbor.dev","user_id":1}`)
+	}))
+	defer harbor.Close()
+
+	global := NewLimiter(100, 0, 4)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(global)
+	for _, instance := range []string{"a", "b"} {
+		opts := DefaultHarborOpts()
+		opts.Url = harbor.URL + "/api"
+		opts.AllowDefaultPassword = true
+		e, err := NewExporter(WithHarborOpts(opts), WithGlobalLimiter(global), WithScrapers(ScrapeSystemInfo{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prometheus.WrapRegistererWith(prometheus.Labels{"instance": instance}, reg).MustRegister(e)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := map[string]int{}
+	for _, mf := range mfs {
+		if mf.GetName() != throttledRequestsMetric.Name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "limiter" {
+					series[l.GetValue()]++
+				}
+			}
+		}
+	}
+	if series[limiterGlobal] != 1 || series[limiterTarget] != 2 {
+		t.Errorf("throttled requests series are %v, want a global one and a target one per exporter", series)
+	}
+}
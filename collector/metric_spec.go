@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricSpec is the metadata of a metric exposed by a scraper or the exporter itself,
+// its Desc is what the scraper sends the metric with.
+type MetricSpec struct {
+	Name   string
+	Help   string
+	Type   prometheus.ValueType
+	Labels []string
+
+	desc *prometheus.Desc
+}
+
+func newMetricSpec(name, help string, valueType prometheus.ValueType, labels ...string) *MetricSpec {
+	return &MetricSpec{
+		Name:   name,
+		Help:   help,
+		Type:   valueType,
+		Labels: labels,
+		desc:   prometheus.NewDesc(name, help, labels, nil),
+	}
+}
+
+// Desc of the metric.
+func (s *MetricSpec) Desc() *prometheus.Desc {
+	return s.desc
+}
+
+// TypeName is the type in the text format, e.g. gauge.
+func (s *MetricSpec) TypeName() string {
+	switch s.Type {
+	case prometheus.CounterValue:
+		return "counter"
+	case prometheus.GaugeValue:
+		return "gauge"
+	default:
+		return "untyped"
+	}
+}
+
+// MetricScraper is implemented by the scrapers which declare the metrics they expose.
+// Exporter.Describe describes every metric when all the scrapers are MetricScrapers,
+// so the registry could detect the collisions, otherwise the Exporter is unchecked.
+type MetricScraper interface {
+	Scraper
+	Metrics() []*MetricSpec
+}
+
+// Metric specs of the exporter itself.
+var (
+	totalScrapesMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "scrapes_total"),
+		"Total number of times harbor was scraped for metrics.",
+		prometheus.CounterValue,
+	)
+	scrapeErrorsMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "scrape_errors_total"),
+		"Total number of times an error occurred scraping a harbor.",
+		prometheus.CounterValue, "collector",
+	)
+	lastScrapeErrorMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "last_scrape_error"),
+		"Whether the last scrape of metrics from harbor resulted in an error (1 for error, 0 for success).",
+		prometheus.GaugeValue,
+	)
+	upMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Whether the harbor is up.",
+		prometheus.GaugeValue,
+	)
+	authValidMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, "", "auth_valid"),
+		"Whether the credentials are accepted by harbor (1 for valid, 0 for invalid), the last result is kept while harbor is down. Absent until the credentials are checked once.",
+		prometheus.GaugeValue,
+	)
+	pingFailureReasonMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "ping_failure_reason"),
+		"The reason why the last ping or credential check failed (1 for the current reason, all 0 when both succeeded).",
+		prometheus.GaugeValue, "reason",
+	)
+	scrapeDurationMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "collector_duration_seconds"),
+		"Collector time duration.",
+		prometheus.GaugeValue, "collector",
+	)
+	collectorSkippedMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "collector_skipped"),
+		"Collectors skipped in the last scrape and why (1 for skipped).",
+		prometheus.GaugeValue, "collector", "reason",
+	)
+	throttledRequestsMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "throttled_requests_total"),
+		"Total number of requests to harbor which waited for the rate limit or the max in flight requests.",
+		prometheus.CounterValue, "limiter",
+	)
+	throttleWaitMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "throttle_wait_seconds_total"),
+		"Total time the requests to harbor waited for the rate limit or the max in flight requests.",
+		prometheus.CounterValue, "limiter",
+	)
+	inFlightRequestsMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "requests_in_flight"),
+		"Number of requests to harbor in flight, only counted when the max in flight requests is set.",
+		prometheus.GaugeValue, "limiter",
+	)
+	cacheHitsMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "response_cache_hits_total"),
+		"Total number of requests to harbor answered by the response cache or shared with an identical request in flight.",
+		prometheus.CounterValue,
+	)
+	cacheMissesMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "response_cache_misses_total"),
+		"Total number of requests to harbor not answered by the response cache.",
+		prometheus.CounterValue,
+	)
+	notModifiedMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "conditional_not_modified_total"),
+		"Total number of conditional requests harbor answered 304 Not Modified, whose kept body was reused.",
+		prometheus.CounterValue,
+	)
+	bytesSavedMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "conditional_bytes_saved_total"),
+		"Total bytes of the bodies reused after 304 Not Modified instead of downloaded again.",
+		prometheus.CounterValue,
+	)
+	conditionalCacheBytesMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, exporter, "conditional_cache_bytes"),
+		"Bytes of the bodies kept for the conditional requests.",
+		prometheus.GaugeValue,
+	)
+)
+
+// exporterMetrics are the metrics exposed whatever scrapers are enabled.
+func exporterMetrics() []*MetricSpec {
+	return []*MetricSpec{
+		totalScrapesMetric,
+		scrapeErrorsMetric,
+		lastScrapeErrorMetric,
+		upMetric,
+		authValidMetric,
+		pingFailureReasonMetric,
+		scrapeDurationMetric,
+		collectorSkippedMetric,
+		throttledRequestsMetric,
+		throttleWaitMetric,
+		inFlightRequestsMetric,
+		cacheHitsMetric,
+		cacheMissesMetric,
+		notModifiedMetric,
+		bytesSavedMetric,
+		conditionalCacheBytesMetric,
+	}
+}
+
+func (s *MetricSpec) counterOpts() prometheus.CounterOpts {
+	return prometheus.CounterOpts{Name: s.Name, Help: s.Help}
+}
+
+func (s *MetricSpec) gaugeOpts() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{Name: s.Name, Help: s.Help}
+}
@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+// check interface
+var _ PermissionScraper = ScrapeConfigurations{}
+var _ MetricScraper = ScrapeConfigurations{}
+
+const (
+	configurationsUrl = "/configurations"
+)
+
+// projectCreationRestrictions are the values of project_creation_restriction,
+// harbor_config_project_creation_restriction has a series for each of them
+var projectCreationRestrictions = []string{"everyone", "adminonly"}
+
+var (
+	configAuthMode = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "auth_mode"),
+		"The auth_mode of /configurations (1 for the current mode, 0 for the other known modes).",
+		prometheus.GaugeValue, "mode",
+	)
+	configSelfRegistration = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "self_registration"),
+		"Whether the users could register themselves (1 for enabled, 0 for disabled).",
+		prometheus.GaugeValue,
+	)
+	configProjectCreationRestriction = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "project_creation_restriction"),
+		"Who could create the projects (1 for the current restriction, 0 for the other known restrictions).",
+		prometheus.GaugeValue, "restriction",
+	)
+	configTokenExpiration = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "token_expiration_minutes"),
+		"Expiration of the tokens issued by harbor in minutes.",
+		prometheus.GaugeValue,
+	)
+	configStoragePerProject = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "storage_per_project_bytes"),
+		"Default storage quota of the new projects in bytes, -1 for unlimited.",
+		prometheus.GaugeValue,
+	)
+	configReadOnly = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "read_only"),
+		"Whether harbor is set to the read-only mode (1 for read-only, 0 for read-write).",
+		prometheus.GaugeValue,
+	)
+	configHash = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "hash_info"),
+		"Hash of the whole /configurations, it changes when any setting is changed.",
+		prometheus.GaugeValue, "hash",
+	)
+	configChanges = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "changes_total"),
+		"Total number of times /configurations was seen changed since the exporter started.",
+		prometheus.CounterValue,
+	)
+	configLastChange = newMetricSpec(
+		prometheus.BuildFQName(namespace, "config", "last_change_timestamp_seconds"),
+		"When /configurations was seen changed last time, or seen first time if it has not changed since the exporter started.",
+		prometheus.GaugeValue,
+	)
+)
+
+// configState is the last seen hash of /configurations, shared by the scrapes.
+type configState struct {
+	mu      sync.Mutex
+	hash    string
+	changes uint64
+	changed time.Time
+}
+
+// observe records the hash and returns the changes and the time of the last change.
+func (s *configState) observe(hash string) (uint64, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.hash == "":
+		s.changed = time.Now()
+	case s.hash != hash:
+		s.changes++
+		s.changed = time.Now()
+	}
+	s.hash = hash
+	return s.changes, s.changed
+}
+
+type ScrapeConfigurations struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeConfigurations) Name() string {
+	return "configurations"
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeConfigurations) Help() string {
+	return "Collect the selected settings and detect the changes of /configurations"
+}
+
+// Metrics exposed by the Scraper.
+func (ScrapeConfigurations) Metrics() []*MetricSpec {
+	return []*MetricSpec{
+		configAuthMode, configSelfRegistration, configProjectCreationRestriction, configTokenExpiration,
+		configStoragePerProject, configReadOnly, configHash, configChanges, configLastChange,
+	}
+}
+
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeConfigurations) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
+
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (ScrapeConfigurations) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	data, err := client.api().Configurations()
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return errors.Wrap(resultErr, configurationsUrl)
+	}
+
+	if mode, ok := configString(data, "auth_mode"); ok {
+		sendEnum(ch, configAuthMode, authModes, mode)
+	}
+	if v, ok := configBool(data, "self_registration"); ok {
+		ch <- prometheus.MustNewConstMetric(configSelfRegistration.Desc(), prometheus.GaugeValue, boolValue(v))
+	}
+	if v, ok := configString(data, "project_creation_restriction"); ok {
+		sendEnum(ch, configProjectCreationRestriction, projectCreationRestrictions, v)
+	}
+	if v, ok := configNumber(data, "token_expiration"); ok {
+		ch <- prometheus.MustNewConstMetric(configTokenExpiration.Desc(), prometheus.GaugeValue, v)
+	}
+	if v, ok := configNumber(data, "storage_per_project"); ok {
+		ch <- prometheus.MustNewConstMetric(configStoragePerProject.Desc(), prometheus.GaugeValue, v)
+	}
+	if v, ok := configBool(data, "read_only"); ok {
+		ch <- prometheus.MustNewConstMetric(configReadOnly.Desc(), prometheus.GaugeValue, boolValue(v))
+	}
+
+	hash, err := configurationsHash(data)
+	if err != nil {
+		return err
+	}
+	changes, changed := client.config.observe(hash)
+	ch <- prometheus.MustNewConstMetric(configHash.Desc(), prometheus.GaugeValue, 1, hash)
+	ch <- prometheus.MustNewConstMetric(configChanges.Desc(), prometheus.CounterValue, float64(changes))
+	ch <- prometheus.MustNewConstMetric(configLastChange.Desc(), prometheus.GaugeValue, float64(changed.Unix()))
+
+	return nil
+}
+
+// configurationsHash is the hash of every setting, the keys are sorted by json.Marshal so it is stable.
+func configurationsHash(data harborclient.Configurations) (string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func configString(data harborclient.Configurations, key string) (string, bool) {
+	item, ok := data[key]
+	if !ok {
+		return "", false
+	}
+	s, ok := item.Value.(string)
+	return s, ok
+}
+
+// configBool parses the bool settings, some versions answer them as strings.
+func configBool(data harborclient.Configurations, key string) (bool, bool) {
+	item, ok := data[key]
+	if !ok {
+		return false, false
+	}
+	switch v := item.Value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// configNumber parses the number settings, some versions answer them as strings.
+func configNumber(data harborclient.Configurations, key string) (float64, bool) {
+	item, ok := data[key]
+	if !ok {
+		return 0, false
+	}
+	switch v := item.Value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestConfigAuthMode(t *testing.T) {
+	var mode string
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"auth_mode":{"value":%q,"editable":false},"project_creation_restriction":{"value":"adminonly","editable":true}}`, mode)
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	client := newTestClient(t, opts)
+
+	for _, c := range []struct {
+		mode string
+		want map[string]float64
+	}{
+		{"ldap_auth", map[string]float64{"db_auth": 0, "ldap_auth": 1, "uaa_auth": 0, "oidc_auth": 0, "http_auth": 0}},
+		{"new_auth", map[string]float64{"db_auth": 0, "ldap_auth": 0, "uaa_auth": 0, "oidc_auth": 0, "http_auth": 0, "new_auth": 1}},
+	} {
+		mode = c.mode
+
+		ch := make(chan prometheus.Metric)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ScrapeConfigurations{}.Scrape(client, ch)
+			close(ch)
+		}()
+		got := map[string]float64{}
+		restrictions := map[string]float64{}
+		for m := range ch {
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				t.Fatal(err)
+			}
+			switch m.Desc() {
+			case configAuthMode.Desc():
+				got[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+			case configProjectCreationRestriction.Desc():
+				restrictions[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+			}
+		}
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("auth modes of %s are %v, want %v", c.mode, got, c.want)
+		}
+		if want := map[string]float64{"everyone": 0, "adminonly": 1}; fmt.Sprint(restrictions) != fmt.Sprint(want) {
+			t.Errorf("restrictions are %v, want %v", restrictions, want)
+		}
+	}
+}
@@ -1,18 +1,19 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // check interface
 var _ Scraper = ScrapeHealth{}
+var _ VersionedScraper = ScrapeHealth{}
+var _ MetricScraper = ScrapeHealth{}
 
 var (
-	healthInfo = prometheus.NewDesc(
+	healthInfo = newMetricSpec(
 		prometheus.BuildFQName(namespace, "", "health"),
 		"components status(0 for error, 1 for success).",
-		[]string{"name"}, nil,
+		prometheus.GaugeValue, "name",
 	)
 )
 
@@ -28,37 +29,31 @@ func (ScrapeHealth) Help() string {
 	return "Collect the health ref work"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeHealth) Metrics() []*MetricSpec {
+	return []*MetricSpec{healthInfo}
+}
+
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeHealth) SupportedVersions() VersionRange {
+	return VersionRange{Min: "1.8.0"}
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
 func (ScrapeHealth) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data healthJson
-	url := "/health"
-	body, err := client.request(url)
+	data, err := client.api().Health()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	for _, v := range data.CS {
+	for _, v := range data.Components {
 		var status float64 = 0
 		if v.Status == "healthy" {
 			status = 1
 		}
-		ch <- prometheus.MustNewConstMetric(healthInfo, prometheus.GaugeValue,
+		ch <- prometheus.MustNewConstMetric(healthInfo.Desc(), prometheus.GaugeValue,
 			status, v.Name)
 	}
 
 	return nil
 }
-
-type healthJson struct {
-	Status string     `json:"status"`
-	CS     []csStatus `json:"components"`
-}
-
-type csStatus struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
-}
@@ -1,21 +1,14 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 )
 
 // check interface
 var _ Scraper = ScrapeLables{}
-
-var (
-	labelsRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "labels"),
-		"test the labels ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
+var _ MetricScraper = ScrapeLables{}
 
 type ScrapeLables struct{}
 
@@ -29,25 +22,23 @@ func (ScrapeLables) Help() string {
 	return "Collect the labels ref work"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeLables) Metrics() []*MetricSpec {
+	return probeMetrics("labels")
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
 func (ScrapeLables) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []idJson
-	url := "/labels?scope=g&pagesize=1"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data) != 1 || data[0].ID == 0 {
-		return errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(labelsRefInfo, prometheus.GaugeValue,
-		1, "/labels", "GET")
-
-	return nil
+	return client.probe(ch, "labels", "/labels", func() error {
+		data, err := client.api().Labels(harborclient.ListOptions{PageSize: 1, Params: map[string]string{"scope": "g"}})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || data[0].ID == 0 {
+			return errors.Wrap(resultErr, "/labels")
+		}
+
+		return nil
+	})
 }
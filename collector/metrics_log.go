@@ -1,22 +1,17 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 )
 
 // check interface
 var _ Scraper = ScrapeLogs{}
+var _ VersionSwitcher = ScrapeLogs{}
+var _ MetricScraper = ScrapeLogs{}
 
-var (
-	logRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "logs"),
-		"test the logs ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
-
+// ScrapeLogs probes /logs of v1, it is switched to scrapeAuditLogs on v2.
 type ScrapeLogs struct{}
 
 // Name of the Scraper. Should be unique.
@@ -29,29 +24,52 @@ func (ScrapeLogs) Help() string {
 	return "Collect the logs ref work"
 }
 
-// Scrape collects data from client and sends it over channel as prometheus metric.
-func (ScrapeLogs) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []logJson
-	url := "/logs?page_size=1"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
+// Metrics exposed by the Scraper.
+func (ScrapeLogs) Metrics() []*MetricSpec {
+	return probeMetrics("logs")
+}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
+// ForVersion returns the implementation of the Scraper for the harbor version.
+func (s ScrapeLogs) ForVersion(v Version) Scraper {
+	if v.Major >= 2 {
+		return scrapeAuditLogs{s}
 	}
+	return s
+}
 
-	if len(data) != 1 || data[0].ID == 0 {
-		return errors.Wrap(resultErr, url)
-	}
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (ScrapeLogs) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "logs", "/logs", func() error {
+		data, err := client.api().Logs(harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(logRefInfo, prometheus.GaugeValue,
-		1, "/logs", "GET")
+		if len(data) != 1 || data[0].LogID == 0 {
+			return errors.Wrap(resultErr, "/logs")
+		}
+
+		return nil
+	})
+}
 
-	return nil
+// scrapeAuditLogs probes /audit-logs which replaces /logs in v2.
+type scrapeAuditLogs struct {
+	ScrapeLogs
 }
 
-type logJson struct {
-	ID int `json:"log_id"`
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (scrapeAuditLogs) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "logs", "/audit-logs", func() error {
+		data, err := client.api().AuditLogs(harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || data[0].ID == 0 {
+			return errors.Wrap(resultErr, "/audit-logs")
+		}
+
+		return nil
+	})
 }
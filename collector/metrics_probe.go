@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+const (
+	apiProbe = "api_probe"
+)
+
+var (
+	probeSuccessMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, apiProbe, "success"),
+		"Whether the api ref works (0 for error, 1 for success).",
+		prometheus.GaugeValue, "area", "ref", "method",
+	)
+	probeDurationMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, apiProbe, "duration_seconds"),
+		"Time consuming of the api probe.",
+		prometheus.GaugeValue, "area", "ref", "method",
+	)
+	probeStatusMetric = newMetricSpec(
+		prometheus.BuildFQName(namespace, apiProbe, "http_status_code"),
+		"HTTP status code answered by harbor for the api probe, 0 if there is no response. The 304 Not Modified whose kept body is reused is reported as 200.",
+		prometheus.GaugeValue, "area", "ref", "method",
+	)
+
+	// Deprecated: the harbor_ref_work_<area> metrics only emit 1 on success,
+	// they are kept behind --compat.ref-work-metrics for migrating dashboards.
+	refWorkMetrics = newRefWorkMetrics("gc", "labels", "logs", "projects", "replication", "repos", "users")
+)
+
+func newRefWorkMetrics(areas ...string) map[string]*MetricSpec {
+	specs := make(map[string]*MetricSpec, len(areas))
+	for _, area := range areas {
+		specs[area] = newMetricSpec(
+			prometheus.BuildFQName(namespace, "ref_work", area),
+			fmt.Sprintf("Deprecated, use %s. test the %s ref work status(0 for error, 1 for success).", probeSuccessMetric.Name, area),
+			prometheus.GaugeValue, "ref", "method",
+		)
+	}
+	return specs
+}
+
+// probeMetrics are the metrics of the probes of the areas, for MetricScraper.Metrics.
+func probeMetrics(areas ...string) []*MetricSpec {
+	specs := []*MetricSpec{probeSuccessMetric, probeDurationMetric, probeStatusMetric}
+	for _, area := range areas {
+		specs = append(specs, refWorkMetrics[area])
+	}
+	return specs
+}
+
+// probe runs check against the api ref of the area and always reports the result,
+// a failed check is exposed as 0 instead of a missing series.
+func (h *HarborClient) probe(ch chan<- prometheus.Metric, area, ref string, check func() error) error {
+	start := time.Now()
+	err := check()
+	duration := time.Since(start).Seconds()
+
+	var success float64
+	if err == nil {
+		success = 1
+	}
+	h.reportProbe(ch, area, ref, success, duration, probeStatusCode(err))
+
+	return err
+}
+
+// skipProbes reports the refs of the area which are not probed because a ref they depend on failed,
+// e.g. /users/{user_id} without a user id, they are exposed as failed without a response.
+func (h *HarborClient) skipProbes(ch chan<- prometheus.Metric, area string, refs ...string) {
+	for _, ref := range refs {
+		h.reportProbe(ch, area, ref, 0, 0, 0)
+	}
+}
+
+func (h *HarborClient) reportProbe(ch chan<- prometheus.Metric, area, ref string, success, duration float64, code int) {
+	const method = "GET"
+
+	ch <- prometheus.MustNewConstMetric(probeSuccessMetric.Desc(), prometheus.GaugeValue, success, area, ref, method)
+	ch <- prometheus.MustNewConstMetric(probeDurationMetric.Desc(), prometheus.GaugeValue, duration, area, ref, method)
+	ch <- prometheus.MustNewConstMetric(probeStatusMetric.Desc(), prometheus.GaugeValue, float64(code), area, ref, method)
+
+	if success == 1 && h.Opts.RefWorkMetrics {
+		if spec, ok := refWorkMetrics[area]; ok {
+			ch <- prometheus.MustNewConstMetric(spec.Desc(), prometheus.GaugeValue, 1, ref, method)
+		}
+	}
+}
+
+// probeStatusCode is the status code answered by harbor for the check error,
+// the decode and result errors happen after harbor answered 200,
+// the others (transport, throttle, auth...) have no response.
+func probeStatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	var se *harborclient.StatusError
+	if errors.As(err, &se) {
+		return se.Code
+	}
+
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	if errors.Is(err, resultErr) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return http.StatusOK
+	}
+
+	return 0
+}
+
+// firstError returns the first non-nil error, the probes of an area keep going after a failure.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+func TestProbeStatusCode(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		code int
+	}{
+		{nil, http.StatusOK},
+		{&harborclient.StatusError{Code: http.StatusForbidden}, http.StatusForbidden},
+		{errors.Wrap(&harborclient.StatusError{Code: http.StatusNotFound}, "/users"), http.StatusNotFound},
+		{errors.Wrap(resultErr, "/users"), http.StatusOK},
+		{fmt.Errorf("decode the response of /users: %w", json.Unmarshal([]byte("{"), &struct{}{})), http.StatusOK},
+		{fmt.Errorf("decode the response of /users: %w", json.Unmarshal([]byte(`"a"`), new(int))), http.StatusOK},
+		{fmt.Errorf("throttled by the global rate limit: %s", context.DeadlineExceeded), 0},
+		{&authenticateError{err: errors.New("login")}, 0},
+		{errors.New("dial tcp: connection refused"), 0},
+	} {
+		if code := probeStatusCode(c.err); code != c.code {
+			t.Errorf("status code of %v is %d, want %d", c.err, code, c.code)
+		}
+	}
+}
+
+// probeResult is the success and the status code of a probed ref.
+type probeResult struct {
+	success, code float64
+}
+
+// scrapeProbes runs the scraper and returns the probe results by the ref.
+func scrapeProbes(t *testing.T, scraper Scraper, client *HarborClient) (map[string]probeResult, error) {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- scraper.Scrape(client, ch)
+		close(ch)
+	}()
+
+	results := make(map[string]probeResult)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		var ref string
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "ref" {
+				ref = l.GetValue()
+			}
+		}
+		r := results[ref]
+		switch m.Desc() {
+		case probeSuccessMetric.Desc():
+			r.success = pb.GetGauge().GetValue()
+		case probeStatusMetric.Desc():
+			r.code = pb.GetGauge().GetValue()
+		}
+		results[ref] = r
+	}
+	return results, <-errCh
+}
+
+func TestSkippedProbes(t *testing.T) {
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/current":
+			io.WriteString(w, `{"user_id":1}`)
+		case "/api/replication/adapters":
+			io.WriteString(w, `["harbor"]`)
+		case "/api/repositories/top":
+			io.WriteString(w, `[]`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	client := newTestClient(t, opts)
+
+	for _, c := range []struct {
+		scraper Scraper
+		want    map[string]probeResult
+	}{
+		{ScrapeUsers{}, map[string]probeResult{
+			"/users":           {0, 500},
+			"/users/{user_id}": {0, 0},
+			"/users/current":   {1, 200},
+		}},
+		{ScrapeReplication{}, map[string]probeResult{
+			"/replication/policies":   {0, 500},
+			"/replication/executions": {0, 0},
+			"/replication/adapters":   {1, 200},
+		}},
+		{ScrapeProjects{}, map[string]probeResult{
+			"/projects":                                    {0, 500},
+			"/projects/{project_id}":                       {0, 0},
+			"/projects/{project_id}/logs":                  {0, 0},
+			"/projects/{project_id}/metadatas":             {0, 0},
+			"/projects/{project_id}/metadatas/{meta_name}": {0, 0},
+			"/projects/{project_id}/members":               {0, 0},
+			"/projects/{project_id}/members/{mid}":         {0, 0},
+			"/repositories":                                {0, 0},
+			"/repositories/top":                            {0, 200},
+		}},
+	} {
+		t.Run(c.scraper.Name(), func(t *testing.T) {
+			got, err := scrapeProbes(t, c.scraper, client)
+			var se *harborclient.StatusError
+			if !errors.As(err, &se) || se.Code != http.StatusInternalServerError {
+				t.Errorf("error is %v, want the first failure", err)
+			}
+			if len(got) != len(c.want) {
+				t.Errorf("probed %v, want %v", got, c.want)
+			}
+			for ref, want := range c.want {
+				if r, ok := got[ref]; !ok || r != want {
+					t.Errorf("%s is %+v, want %+v", ref, r, want)
+				}
+			}
+		})
+	}
+}
@@ -1,41 +1,24 @@
 package collector
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 	"strconv"
 )
 
 // check interface
 var _ Scraper = ScrapeProjects{}
+var _ VersionedScraper = ScrapeProjects{}
+var _ VersionSwitcher = ScrapeProjects{}
+var _ MetricScraper = ScrapeProjects{}
 
 const (
 	projectsUrl = "/projects"
 )
 
-var (
-	projectsRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "projects"),
-		"test the projects api ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-	reposRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "repos"),
-		"test the repos api ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
-
-type projectsJson struct {
-	ProjectID int `json:"project_id"`
-}
-
-type metadataJson struct {
-	Public string `json:"public"`
-}
-
+// ScrapeProjects probes the projects and repositories of v1, it is switched to scrapeProjectsV2 on v2.
 type ScrapeProjects struct{}
 
 // Name of the Scraper. Should be unique.
@@ -48,363 +31,264 @@ func (ScrapeProjects) Help() string {
 	return "Collect the projects and repos api work"
 }
 
-// Scrape collects data from client and sends it over channel as prometheus metric.
-func (ScrapeProjects) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var (
-		id  int
-		err error
-	)
-
-	id, err = projects(client, ch)
-	if err != nil {
-		return err
-	}
-
-	err = projectsLogs(id, client, ch)
-	if err != nil {
-		return err
-	}
+// Metrics exposed by the Scraper.
+func (ScrapeProjects) Metrics() []*MetricSpec {
+	return probeMetrics("projects", "repos")
+}
 
-	err = projectsMetadata(id, client, ch)
-	if err != nil {
-		return err
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeProjects) SupportedVersions() VersionRange {
+	return VersionRange{
+		// https://github.com/goharbor/harbor/issues/12273
+		Broken: []string{"1.8.1"},
 	}
+}
 
-	err = projectsMembers(id, client, ch)
-	if err != nil {
-		return err
-	}
-
-	err = reposQuery(id, client, ch)
-	if err != nil {
-		return err
+// ForVersion returns the implementation of the Scraper for the harbor version.
+func (s ScrapeProjects) ForVersion(v Version) Scraper {
+	if v.Major >= 2 {
+		return scrapeProjectsV2{s}
 	}
+	return s
+}
 
-	err = reposTop(client, ch)
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (ScrapeProjects) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	project, err := projects(client, ch)
+	id := project.ProjectID
 	if err != nil {
-		return err
-	}
-
-	return nil
+		// the refs of the project need a project id
+		client.skipProbes(ch, "projects",
+			"/projects/{project_id}/logs",
+			"/projects/{project_id}/metadatas",
+			"/projects/{project_id}/metadatas/{meta_name}",
+			"/projects/{project_id}/members",
+			"/projects/{project_id}/members/{mid}",
+		)
+		client.skipProbes(ch, "repos", "/repositories")
+	} else {
+		err = firstError(
+			projectsLogs(id, client, ch),
+			projectsMetadata(id, client, ch),
+			projectsMembers(id, client, ch),
+			reposQuery(id, client, ch),
+		)
+	}
+
+	return firstError(err, reposTop(client, ch))
 }
 
-func projects(client *HarborClient, ch chan<- prometheus.Metric) (int, error) {
-	var data []projectsJson
-	url := projectsUrl + "?page_size=1&public=true"
-	body, err := client.request(url)
-	if err != nil {
-		return 0, err
-	}
+func projects(client *HarborClient, ch chan<- prometheus.Metric) (harborclient.Project, error) {
+	var data []harborclient.Project
+	err := client.probe(ch, "projects", projectsUrl, func() (err error) {
+		data, err = client.api().Projects(harborclient.ListOptions{PageSize: 1, Params: map[string]string{"public": "true"}})
+		if err != nil {
+			return err
+		}
 
-	if err = json.Unmarshal(body, &data); err != nil {
-		return 0, err
-	}
+		if len(data) != 1 || data[0].ProjectID == 0 {
+			return errors.Wrap(resultErr, projectsUrl)
+		}
 
-	if len(data) != 1 || data[0].ProjectID == 0 {
-		return 0, errors.Wrap(resultErr, url)
+		return nil
+	})
+	if err != nil {
+		client.skipProbes(ch, "projects", "/projects/{project_id}")
+		return harborclient.Project{}, err
 	}
 
 	id := data[0].ProjectID
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, projectsUrl, "GET")
-
-	var result projectsJson
-
-	url = projectsUrl + "/" + strconv.Itoa(id)
-	body, err = client.request(url)
+	err = client.probe(ch, "projects", "/projects/{project_id}", func() error {
+		result, err := client.api().Project(id)
+		if err != nil {
+			return err
+		}
+
+		if result.ProjectID == 0 {
+			return errors.Wrap(resultErr, projectsUrl+"/"+strconv.Itoa(id))
+		}
+
+		return nil
+	})
 	if err != nil {
-		return 0, err
+		return harborclient.Project{}, err
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	if result.ProjectID == 0 {
-		return 0, errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}", "GET")
-
-	return id, nil
+	return data[0], nil
 }
 
 func projectsLogs(id int, client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []projectsJson
-	url := fmt.Sprintf("/projects/%d/logs?page_size=1", id)
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data) != 1 || data[0].ProjectID == 0 {
-		return errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}/logs", "GET")
-
-	return nil
+	return client.probe(ch, "projects", "/projects/{project_id}/logs", func() error {
+		data, err := client.api().ProjectLogs(id, harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || data[0].ProjectID == 0 {
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%d/logs", id))
+		}
+
+		return nil
+	})
 }
 
 func projectsMetadata(id int, client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data metadataJson
-	url := fmt.Sprintf("/projects/%d/metadatas", id)
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data.Public) == 0 {
-		return fmt.Errorf("cannot find the metadatas by %s", url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}/metadatas", "GET")
-
-	url = fmt.Sprintf("/projects/%d/metadatas/public", id)
-	body, err = client.request(url)
+	err := client.probe(ch, "projects", "/projects/{project_id}/metadatas", func() error {
+		data, err := client.api().ProjectMetadatas(id)
+		if err != nil {
+			return err
+		}
+
+		if len(data["public"]) == 0 {
+			return errors.Wrapf(resultErr, "cannot find the metadatas by /projects/%d/metadatas", id)
+		}
+
+		return nil
+	})
 	if err != nil {
+		client.skipProbes(ch, "projects", "/projects/{project_id}/metadatas/{meta_name}")
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
+	return client.probe(ch, "projects", "/projects/{project_id}/metadatas/{meta_name}", func() error {
+		data, err := client.api().ProjectMetadata(id, "public")
+		if err != nil {
+			return err
+		}
 
-	if len(data.Public) == 0 {
-		return errors.Wrap(resultErr, url)
-	}
+		if len(data["public"]) == 0 {
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%d/metadatas/public", id))
+		}
 
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}/metadatas/{meta_name}", "GET")
-
-	return nil
+		return nil
+	})
 }
 
 func projectsMembers(id int, client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []subInsJson
-	url := fmt.Sprintf("/projects/%d/members", id)
-	body, err := client.request(url)
+	var data []harborclient.ProjectMember
+	err := client.probe(ch, "projects", "/projects/{project_id}/members", func() (err error) {
+		data, err = client.api().ProjectMembers(id)
+		if err != nil {
+			return err
+		}
+
+		if len(data) == 0 || data[0].ID == 0 { // will response the all members
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%d/members", id))
+		}
+
+		return nil
+	})
 	if err != nil {
+		client.skipProbes(ch, "projects", "/projects/{project_id}/members/{mid}")
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data) == 0 || data[0].Id == 0 { // will response the all members
-		return errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}/members", "GET")
-
-	var result subInsJson
-	// some version (e.g., v1.8.1 https://github.com/goharbor/harbor/issues/12273), It will return 403
-	url = fmt.Sprintf("/projects/%d/members/%d", id, data[0].Id)
-	body, err = client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return err
-	}
-
-	if result.Id == 0 {
-		return errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(projectsRefInfo, prometheus.GaugeValue,
-		1, "/projects/{project_id}/members/{mid}", "GET")
+	return client.probe(ch, "projects", "/projects/{project_id}/members/{mid}", func() error {
+		// some version (e.g., v1.8.1 https://github.com/goharbor/harbor/issues/12273), It will return 403
+		result, err := client.api().ProjectMember(id, data[0].ID)
+		if err != nil {
+			return err
+		}
 
-	return nil
-}
+		if result.ID == 0 {
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%d/members/%d", id, data[0].ID))
+		}
 
-type repoJson struct {
-	subInsJson
-	Name string `json:"name"`
+		return nil
+	})
 }
 
 // first arg must be project_id
 func reposQuery(id int, client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []repoJson
-	url := "/repositories?page_size=1&project_id=" + strconv.Itoa(id)
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data) != 1 || len(data[0].Name) == 0 {
-		return errors.Wrap(resultErr, url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(reposRefInfo, prometheus.GaugeValue,
-		1, "/repositories", "GET")
-
-	return nil
+	return client.probe(ch, "repos", "/repositories", func() error {
+		data, err := client.api().Repositories(id, harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || len(data[0].Name) == 0 {
+			return errors.Wrap(resultErr, "/repositories?project_id="+strconv.Itoa(id))
+		}
+
+		return nil
+	})
 }
 
 // TODO
 //  enable `notary_signer` for /repositories/{repo_name}/signatures
 //  tags always return the all tags https://github.com/goharbor/harbor/issues/12279
-//func tagsQuery(repo string, client *HarborClient, ch chan<- prometheus.Metric)  error {
-//	var data []repoJson
-//	url := "/repositories/" + repo + "/tags"
-//	body, err := client.request(url)
-//	if err != nil {
-//		return err
-//	}
-//
-//	if err := json.Unmarshal(body, &data); err != nil {
-//		return err
-//	}
-//
-//	if len(data) != 1 || len(data[0].Name) == 0 {
-//		return fmt.Errorf("cannot find the repo by %s", url)
-//	}
-//
-//	ch <- prometheus.MustNewConstMetric(reposRefInfo, prometheus.GaugeValue,
-//		1, "/repositories/{repo_name}/tags", "GET")
-//
-//	return nil
-//}
 
 func reposTop(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []repoJson
-	url := "/repositories/top" + "?count=1"
-	body, err := client.request(url)
+	return client.probe(ch, "repos", "/repositories/top", func() error {
+		data, err := client.api().TopRepositories(1)
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || len(data[0].Name) == 0 {
+			return errors.Wrap(resultErr, "cannot find the repo by /repositories/top")
+		}
+
+		return nil
+	})
+}
+
+// scrapeProjectsV2 probes the projects and repositories of v2,
+// the logs and repositories are moved under /projects/{project_name} and /repositories/top is gone.
+type scrapeProjectsV2 struct {
+	ScrapeProjects
+}
+
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (scrapeProjectsV2) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	project, err := projects(client, ch)
 	if err != nil {
+		// the refs of the project need a project id or name
+		client.skipProbes(ch, "projects",
+			"/projects/{project_name}/logs",
+			"/projects/{project_id}/metadatas",
+			"/projects/{project_id}/metadatas/{meta_name}",
+			"/projects/{project_id}/members",
+			"/projects/{project_id}/members/{mid}",
+		)
+		client.skipProbes(ch, "repos", "/projects/{project_name}/repositories")
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
+	return firstError(
+		projectAuditLogs(project.Name, client, ch),
+		projectsMetadata(project.ProjectID, client, ch),
+		projectsMembers(project.ProjectID, client, ch),
+		projectRepositories(project.Name, client, ch),
+	)
+}
 
-	if len(data) != 1 || len(data[0].Name) == 0 {
-		return fmt.Errorf("cannot find the repo by %s", url)
-	}
+func projectAuditLogs(name string, client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "projects", "/projects/{project_name}/logs", func() error {
+		data, err := client.api().ProjectAuditLogs(name, harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(reposRefInfo, prometheus.GaugeValue,
-		1, "/repositories/top", "GET")
+		if len(data) != 1 || data[0].ID == 0 {
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%s/logs", name))
+		}
 
-	return nil
+		return nil
+	})
 }
 
-//const (
-//	projectsUrl  = "/projects"
-//	projectIDUrl = "/repositories?project_id="
-//)
-//
-//var (
-//	repositoriesPullCount = prometheus.NewDesc(
-//		prometheus.BuildFQName(namespace, "", "repositories_pull_total"),
-//		"Get public repositories which are accessed most.).",
-//		[]string{"repo_name", "public"}, nil,
-//	)
-//	repositoriesStarCount = prometheus.NewDesc(
-//		prometheus.BuildFQName(namespace, "", "repositories_star_total"),
-//		"Get public repositories which are accessed most.).",
-//		[]string{"repo_name", "public"}, nil,
-//	)
-//	repositoriesTagCount = prometheus.NewDesc(
-//		prometheus.BuildFQName(namespace, "", "repositories_tags_total"),
-//		"Get public repositories which are accessed most.).",
-//		[]string{"repo_name", "public"}, nil,
-//	)
-//)
-//
-//type ScrapeProjects struct{}
-//
-//// Name of the Scraper. Should be unique.
-//func (ScrapeProjects) Name() string {
-//	return "projects"
-//}
-//
-//// Help describes the role of the Scraper.
-//func (ScrapeProjects) Help() string {
-//	return "Collect the projects"
-//}
-//
-//// Scrape collects data from client and sends it over channel as prometheus metric.
-//func (s ScrapeProjects) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-//	var data []projectsJson
-//	body, err := client.request(projectsUrl)
-//	if err != nil {
-//		return err
-//	}
-//
-//	if err := json.Unmarshal(body, &data); err != nil {
-//		return err
-//	}
-//
-//	for i := 0; i < len(data); i++ {
-//		var repoData []repositoriesJson
-//
-//		repoBody, err := client.request(projectIDUrl + strconv.Itoa(data[i].ProjectID))
-//		if err != nil {
-//			log.WithField("scraper", s.Name()).Error(err)
-//			continue
-//		}
-//		if err := json.Unmarshal(repoBody, &repoData); err != nil {
-//			log.WithField("scraper", s.Name()).Error(err)
-//			continue
-//		}
-//		for j := 0; j < len(repoData); j++ {
-//			ch <- prometheus.MustNewConstMetric(repositoriesPullCount, prometheus.GaugeValue,
-//				repoData[j].PullCount, repoData[j].Name, data[i].Metadata.Public)
-//			ch <- prometheus.MustNewConstMetric(repositoriesStarCount, prometheus.GaugeValue,
-//				repoData[j].StarCount, repoData[j].Name, data[i].Metadata.Public)
-//			ch <- prometheus.MustNewConstMetric(repositoriesTagCount, prometheus.GaugeValue,
-//				repoData[j].TagsCount, repoData[j].Name, data[i].Metadata.Public)
-//		}
-//
-//	}
-//
-//	return nil
-//}
-
-//type projectsJson struct {
-//	ProjectID int `json:"project_id"`
-//OwnerID           int       `json:"owner_id"`
-//Name              string    `json:"name"`
-//CreationTime      time.Time `json:"creation_time"`
-//UpdateTime        time.Time `json:"update_time"`
-//Deleted           int       `json:"deleted"`
-//OwnerName         string    `json:"owner_name"`
-//Togglable         bool      `json:"togglable"`
-//CurrentUserRoleID int       `json:"current_user_role_id"`
-//RepoCount         int       `json:"repo_count"`
-//	Metadata struct {
-//		Public string `json:"public"`
-//	} `json:"metadata"`
-//}
-
-//type repositoriesJson struct {
-//ID           int           `json:"id"`
-//Name string `json:"name"`
-//ProjectID    int           `json:"project_id"`
-//Description  string        `json:"description"`
-//PullCount float64 `json:"pull_count"`
-//StarCount float64 `json:"star_count"`
-//TagsCount float64 `json:"tags_count"`
-//Labels       []interface{} `json:"labels"`
-//CreationTime time.Time     `json:"creation_time"`
-//UpdateTime   time.Time     `json:"update_time"`
-//}
+func projectRepositories(name string, client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "repos", "/projects/{project_name}/repositories", func() error {
+		data, err := client.api().ProjectRepositories(name, harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || len(data[0].Name) == 0 {
+			return errors.Wrap(resultErr, fmt.Sprintf("/projects/%s/repositories", name))
+		}
+
+		return nil
+	})
+}
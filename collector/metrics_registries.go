@@ -1,29 +1,28 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"strings"
 )
 
 // check interface
-var _ Scraper = ScrapeRegistries{}
+var _ PermissionScraper = ScrapeRegistries{}
+var _ VersionedScraper = ScrapeRegistries{}
+var _ MetricScraper = ScrapeRegistries{}
 
 const (
 	registryUrl = "/registries"
 )
 
 var (
-	registriesRefInfo = prometheus.NewDesc(
+	registriesRefInfo = newMetricSpec(
 		prometheus.BuildFQName(namespace, "registries", "healthy"),
 		" ui /harbor/registries status(0 for error, 1 for success).",
-		[]string{"name"}, nil,
+		prometheus.GaugeValue, "name",
 	)
-
 )
 
-
 type ScrapeRegistries struct{}
 
 // Name of the Scraper. Should be unique.
@@ -36,21 +35,30 @@ func (ScrapeRegistries) Help() string {
 	return "Collect the registries and repos api work"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeRegistries) Metrics() []*MetricSpec {
+	return []*MetricSpec{registriesRefInfo}
+}
+
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeRegistries) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
+
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeRegistries) SupportedVersions() VersionRange {
+	return VersionRange{Min: "1.8.0"}
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
 func (ScrapeRegistries) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []registryJson
-	url := registryUrl
-	body, err := client.request(url)
+	data, err := client.api().Registries()
 	if err != nil {
 		return err
 	}
 
-	if err = json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
 	if len(data) == 0 {
-		return errors.Wrap(resultErr, url)
+		return errors.Wrap(resultErr, registryUrl)
 	}
 
 	for _, v := range data {
@@ -58,15 +66,9 @@ func (ScrapeRegistries) Scrape(client *HarborClient, ch chan<- prometheus.Metric
 		if strings.Compare("unhealthy", v.Status) != 0 {
 			status = 1
 		}
-		ch <- prometheus.MustNewConstMetric(registriesRefInfo, prometheus.GaugeValue,
+		ch <- prometheus.MustNewConstMetric(registriesRefInfo.Desc(), prometheus.GaugeValue,
 			status, v.Name)
 	}
 
 	return nil
 }
-
-
-type registryJson struct {
-	Name string `json:"name"`
-	Status string `json:"status"`
-}
\ No newline at end of file
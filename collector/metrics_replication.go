@@ -1,22 +1,16 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 	"strconv"
 )
 
 // check interface
-var _ Scraper = ScrapeReplication{}
-
-var (
-	replicationRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "replication"),
-		"test the replication ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
+var _ PermissionScraper = ScrapeReplication{}
+var _ VersionedScraper = ScrapeReplication{}
+var _ MetricScraper = ScrapeReplication{}
 
 type ScrapeReplication struct{}
 
@@ -30,61 +24,68 @@ func (ScrapeReplication) Help() string {
 	return "Collect the replication ref work"
 }
 
-// Scrape collects data from client and sends it over channel as prometheus metric.
-func (ScrapeReplication) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []idJson
-	url := "/replication/policies?page_size=1"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
+// Metrics exposed by the Scraper.
+func (ScrapeReplication) Metrics() []*MetricSpec {
+	return probeMetrics("replication")
+}
 
-	if len(data) != 1 || data[0].ID == 0 {
-		return errors.Wrap(resultErr, url)
-	}
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeReplication) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
 
-	ch <- prometheus.MustNewConstMetric(replicationRefInfo, prometheus.GaugeValue,
-		1, "/replication/policies", "GET")
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeReplication) SupportedVersions() VersionRange {
+	return VersionRange{Min: "1.8.0"}
+}
 
-	var policy idJson
-	url = "/replication/executions?page=1&page_size=1&policy_id=" + strconv.Itoa(data[0].ID)
-	body, err = client.request(url)
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (ScrapeReplication) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	var policies []harborclient.ReplicationPolicy
+	err := client.probe(ch, "replication", "/replication/policies", func() (err error) {
+		policies, err = client.api().ReplicationPolicies(harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(policies) != 1 || policies[0].ID == 0 {
+			return errors.Wrap(resultErr, "/replication/policies")
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &policy); err != nil {
-		return err
+		// the executions are listed by a policy id
+		client.skipProbes(ch, "replication", "/replication/executions")
+	} else {
+		err = replicationExecutions(policies[0].ID, client, ch)
 	}
 
-	if policy.ID == 0 {
-		return errors.Wrap(resultErr, url)
-	}
+	return firstError(err, client.probe(ch, "replication", "/replication/adapters", func() error {
+		adadapt, err := client.api().ReplicationAdapters()
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(replicationRefInfo, prometheus.GaugeValue,
-		1, "/replication/executions", "GET")
+		if len(adadapt) == 0 {
+			return errors.Wrap(resultErr, "/replication/adapters")
+		}
 
-	var adadapt []string
-	url = "/replication/adapters"
-	body, err = client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &adadapt); err != nil {
-		return err
-	}
+		return nil
+	}))
+}
 
-	if len(adadapt) == 0 {
-		return errors.Wrap(resultErr, url)
-	}
+func replicationExecutions(policyID int, client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "replication", "/replication/executions", func() error {
+		executions, err := client.api().ReplicationExecutions(policyID, harborclient.ListOptions{Page: 1, PageSize: 1})
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(replicationRefInfo, prometheus.GaugeValue,
-		1, url, "GET")
+		if len(executions) != 1 || executions[0].ID == 0 {
+			return errors.Wrap(resultErr, "/replication/executions?policy_id="+strconv.Itoa(policyID))
+		}
 
-	return nil
+		return nil
+	})
 }
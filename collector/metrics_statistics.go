@@ -1,25 +1,35 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // check interface
-var _ Scraper = ScrapeSystemInfo{}
+var _ Scraper = ScrapeStatistics{}
+var _ MetricScraper = ScrapeStatistics{}
 
 const (
 	statisticsUrl = "/statistics"
 )
 
 var (
-	projectCount = prometheus.NewDesc(
+	projectCount = newMetricSpec(
 		prometheus.BuildFQName(namespace, "", "project_count_total"),
-		"projects number relevant to the user", []string{"type"}, nil)
-	repoCount = prometheus.NewDesc(
+		"projects number relevant to the user", prometheus.GaugeValue, "type")
+	repoCount = newMetricSpec(
 		prometheus.BuildFQName(namespace, "", "repo_count_total"),
 		"repositories number relevant to the user",
-		[]string{"type"}, nil,
+		prometheus.GaugeValue, "type",
+	)
+	storageConsumption = newMetricSpec(
+		prometheus.BuildFQName(namespace, "", "storage_consumption_bytes"),
+		"Total storage consumption of harbor in bytes, since v2.",
+		prometheus.GaugeValue,
+	)
+	statisticsFieldAvailable = newMetricSpec(
+		prometheus.BuildFQName(namespace, "statistics", "field_available"),
+		"Whether harbor answered the field of /statistics (1 for answered, 0 for absent), the metric of an absent field is not exposed instead of 0.",
+		prometheus.GaugeValue, "field",
 	)
 )
 
@@ -35,50 +45,43 @@ func (ScrapeStatistics) Help() string {
 	return "Collect the statistics"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeStatistics) Metrics() []*MetricSpec {
+	return []*MetricSpec{projectCount, repoCount, storageConsumption, statisticsFieldAvailable}
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
+// The totals are only answered to the system admin and the storage consumption since v2,
+// the absent fields are reported by harbor_statistics_field_available instead of as 0.
 func (ScrapeStatistics) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data statisticsJson
-	body, err := client.request(statisticsUrl)
+	data, err := client.api().Statistics()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
+	fields := []struct {
+		name   string
+		value  *float64
+		spec   *MetricSpec
+		labels []string
+	}{
+		{"total_project_count", data.TotalProjectCount, projectCount, []string{"total"}},
+		{"public_project_count", data.PublicProjectCount, projectCount, []string{"public"}},
+		{"private_project_count", data.PrivateProjectCount, projectCount, []string{"private"}},
+		{"public_repo_count", data.PublicRepoCount, repoCount, []string{"public"}},
+		{"total_repo_count", data.TotalRepoCount, repoCount, []string{"total"}},
+		{"private_repo_count", data.PrivateRepoCount, repoCount, []string{"private"}},
+		{"total_storage_consumption", data.TotalStorageConsumption, storageConsumption, nil},
 	}
 
-	ch <- prometheus.MustNewConstMetric(
-		projectCount, prometheus.GaugeValue, data.TotalProjectCount, "total",
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		projectCount, prometheus.GaugeValue, data.PublicProjectCount, "public",
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		projectCount, prometheus.GaugeValue, data.PrivateProjectCount, "private",
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		repoCount, prometheus.GaugeValue, data.PublicRepoCount, "public",
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		repoCount, prometheus.GaugeValue, data.TotalRepoCount, "total",
-	)
-
-	ch <- prometheus.MustNewConstMetric(
-		repoCount, prometheus.GaugeValue, data.PrivateRepoCount, "private",
-	)
+	for _, f := range fields {
+		if f.value == nil {
+			ch <- prometheus.MustNewConstMetric(statisticsFieldAvailable.Desc(), prometheus.GaugeValue, 0, f.name)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(statisticsFieldAvailable.Desc(), prometheus.GaugeValue, 1, f.name)
+		ch <- prometheus.MustNewConstMetric(f.spec.Desc(), prometheus.GaugeValue, *f.value, f.labels...)
+	}
 
 	return nil
 }
-
-type statisticsJson struct {
-	PrivateProjectCount float64 `json:"private_project_count"`
-	PrivateRepoCount    float64 `json:"private_repo_count"`
-	PublicProjectCount  float64 `json:"public_project_count"`
-	PublicRepoCount     float64 `json:"public_repo_count"`
-	TotalProjectCount   float64 `json:"total_project_count"`
-	TotalRepoCount      float64 `json:"total_repo_count"`
-}
@@ -1,21 +1,15 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 )
 
 // check interface
-var _ Scraper = ScrapeGc{}
-
-var (
-	gcRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "gc"),
-		"test the replication ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
+var _ PermissionScraper = ScrapeGc{}
+var _ VersionedScraper = ScrapeGc{}
+var _ MetricScraper = ScrapeGc{}
 
 type ScrapeGc struct{}
 
@@ -29,26 +23,33 @@ func (ScrapeGc) Help() string {
 	return "Collect the systemgc ref work"
 }
 
-// Scrape collects data from client and sends it over channel as prometheus metric.
-func (ScrapeGc) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-
-	var data []idJson
-	url := "/system/gc"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
+// Metrics exposed by the Scraper.
+func (ScrapeGc) Metrics() []*MetricSpec {
+	return probeMetrics("gc")
+}
 
-	if len(data) == 0 { //没有page_size参数
-		return errors.Wrap(resultErr, url)
-	}
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeGc) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
 
-	ch <- prometheus.MustNewConstMetric(gcRefInfo, prometheus.GaugeValue,
-		1, "/system/gc", "GET")
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeGc) SupportedVersions() VersionRange {
+	return VersionRange{Min: "1.7.0"}
+}
 
-	return nil
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (ScrapeGc) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	return client.probe(ch, "gc", "/system/gc", func() error {
+		data, err := client.api().GCHistory(harborclient.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		if len(data) == 0 { //没有page_size参数
+			return errors.Wrap(resultErr, "/system/gc")
+		}
+
+		return nil
+	})
 }
@@ -1,24 +1,45 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 	"strconv"
 )
 
 // check interface
 var _ Scraper = ScrapeSystemInfo{}
+var _ MetricScraper = ScrapeSystemInfo{}
 
 const (
 	systemInfoUrl = "/systeminfo"
 )
 
+// authModes are the auth modes of harbor, harbor_system_auth_mode has a series for each of them
+var authModes = []string{"db_auth", "ldap_auth", "uaa_auth", "oidc_auth", "http_auth"}
+
 var (
-	HarborVersion = "" // some version is not contains version number, so could by be override
-	harborInfo    = prometheus.NewDesc(prometheus.BuildFQName(namespace, "version", "info"),
+	harborInfo = newMetricSpec(prometheus.BuildFQName(namespace, "version", "info"),
 		"harbor system info",
-		[]string{"registry_url", "project_creation_restriction", "self_registration", "version"}, nil)
+		prometheus.GaugeValue, "registry_url", "project_creation_restriction", "self_registration", "version")
+	// read_only is not a label, the series would change when it is flipped, see harbor_system_read_only
+	systemInfo = newMetricSpec(prometheus.BuildFQName(namespace, "system", "info"),
+		"harbor system info with the features and the settings of /systeminfo, the fields missing in the harbor version are empty.",
+		prometheus.GaugeValue, "version", "registry_url", "external_url", "auth_mode", "primary_auth_mode", "oidc_provider_name",
+		"project_creation_restriction", "self_registration", "has_ca_root", "with_notary", "with_chartmuseum", "with_clair",
+		"notification_enable", "registry_storage_provider_name")
+	systemReadOnly = newMetricSpec(prometheus.BuildFQName(namespace, "system", "read_only"),
+		"Whether harbor is in the read-only mode (1 for read-only, 0 for read-write), absent when /systeminfo doesn't answer it.",
+		prometheus.GaugeValue)
+	systemAuthMode = newMetricSpec(prometheus.BuildFQName(namespace, "system", "auth_mode"),
+		"The auth mode of harbor (1 for the current mode, 0 for the other known modes).",
+		prometheus.GaugeValue, "mode")
+	systemFeature = newMetricSpec(prometheus.BuildFQName(namespace, "system", "feature_enabled"),
+		"Whether a feature of harbor is enabled (1 for enabled, 0 for disabled).",
+		prometheus.GaugeValue, "feature")
+	clairUpdated = newMetricSpec(prometheus.BuildFQName(namespace, "clair", "vulnerability_db_updated_timestamp_seconds"),
+		"When the vulnerability database of clair was updated last time, only harbor v1.",
+		prometheus.GaugeValue)
 )
 
 type ScrapeSystemInfo struct{}
@@ -33,45 +54,106 @@ func (ScrapeSystemInfo) Help() string {
 	return "Collect the general system info"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeSystemInfo) Metrics() []*MetricSpec {
+	return []*MetricSpec{harborInfo, systemInfo, systemReadOnly, systemAuthMode, systemFeature, clairUpdated}
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
 func (ScrapeSystemInfo) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data systemInfoJson
-	body, err := client.request(systemInfoUrl)
+	data, err := client.api().SystemInfo()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
+	if client.Opts.OverrideVersion != "" {
+		data.HarborVersion = client.Opts.OverrideVersion
+	}
+
+	// the fields of the other major version are not answered, their labels are empty instead of false,
+	// all of them are kept when the version is unknown
+	v, known := client.harborVersion()
+	v1, v2 := !known || v.Major < 2, !known || v.Major >= 2
+
+	// the gauges don't need the registry_url, they are sent even if it is missing
+	// read_only is not sent by every version, the absent one is not exposed instead of as read-write
+	if data.ReadOnly != nil {
+		ch <- prometheus.MustNewConstMetric(systemReadOnly.Desc(), prometheus.GaugeValue, boolValue(*data.ReadOnly))
+	}
+
+	if data.AuthMode != "" {
+		sendEnum(ch, systemAuthMode, authModes, data.AuthMode)
+	}
+
+	for feature, enabled := range systemFeatures(data, v1) {
+		ch <- prometheus.MustNewConstMetric(systemFeature.Desc(), prometheus.GaugeValue, boolValue(enabled), feature)
+	}
+
+	if data.ClairVulnStatus != nil && data.ClairVulnStatus.OverallLastUpdate > 0 {
+		ch <- prometheus.MustNewConstMetric(clairUpdated.Desc(), prometheus.GaugeValue, float64(data.ClairVulnStatus.OverallLastUpdate))
 	}
 
 	if len(data.RegistryURL) == 0 {
 		return errors.Wrap(resultErr, systemInfoUrl)
 	}
 
-	if HarborVersion != "" {
-		data.HarborVersion = HarborVersion
+	ch <- prometheus.MustNewConstMetric(harborInfo.Desc(), prometheus.GaugeValue, 1,
+		data.RegistryURL, data.ProjectCreationRestriction, strconv.FormatBool(data.SelfRegistration), data.HarborVersion)
+	v1Bool := func(b bool) string {
+		if !v1 {
+			return ""
+		}
+		return strconv.FormatBool(b)
+	}
+	v2Bool := func(b bool) string {
+		if !v2 {
+			return ""
+		}
+		return strconv.FormatBool(b)
 	}
 
-	ch <- prometheus.MustNewConstMetric(harborInfo, prometheus.GaugeValue, 1,
-		data.RegistryURL, data.ProjectCreationRestriction, strconv.FormatBool(data.SelfRegistration), data.HarborVersion)
+	ch <- prometheus.MustNewConstMetric(systemInfo.Desc(), prometheus.GaugeValue, 1,
+		data.HarborVersion, data.RegistryURL, data.ExternalURL, data.AuthMode, v2Bool(data.PrimaryAuthMode), data.OIDCProviderName,
+		data.ProjectCreationRestriction, strconv.FormatBool(data.SelfRegistration), strconv.FormatBool(data.HasCaRoot),
+		strconv.FormatBool(data.WithNotary), strconv.FormatBool(data.WithChartmuseum), v1Bool(data.WithClair),
+		strconv.FormatBool(data.NotificationEnable), data.RegistryStorageProviderName)
 
 	return nil
 }
 
-type systemInfoJson struct {
-	//WithNotary                  bool   `json:"with_notary"`
-	//WithClair                   bool   `json:"with_clair"`
-	//WithAdmiral                 bool   `json:"with_admiral"`
-	//AdmiralEndpoint             string `json:"admiral_endpoint"`
-	//AuthMode                    string `json:"auth_mode"`
-	RegistryURL string `json:"registry_url"`
-	//ExternalURL                 string `json:"external_url"`
-	ProjectCreationRestriction string `json:"project_creation_restriction"`
-	SelfRegistration           bool   `json:"self_registration"`
-	//HasCaRoot                   bool   `json:"has_ca_root"`
-	HarborVersion string `json:"harbor_version"`
-	//RegistryStorageProviderName string `json:"registry_storage_provider_name"` //
-	//ReadOnly                    bool   `json:"read_only"`
-	//WithChartmuseum             bool   `json:"with_chartmuseum"` //
+// systemFeatures are the features of the harbor version and whether they are enabled,
+// clair and admiral are gone since v2.
+func systemFeatures(data *harborclient.SystemInfo, v1 bool) map[string]bool {
+	features := map[string]bool{
+		"self_registration": data.SelfRegistration,
+		"notary":            data.WithNotary,
+		"chartmuseum":       data.WithChartmuseum,
+		"notification":      data.NotificationEnable,
+		"ca_root":           data.HasCaRoot,
+	}
+	if v1 {
+		features["clair"] = data.WithClair
+		features["admiral"] = data.WithAdmiral
+	}
+	return features
+}
+
+// sendEnum sends a series of spec for each known value, 1 for the current one and 0 for the others,
+// so the alerts could match on 0, the unknown current value gets a series as well.
+func sendEnum(ch chan<- prometheus.Metric, spec *MetricSpec, known []string, current string) {
+	isKnown := false
+	for _, v := range known {
+		isKnown = isKnown || v == current
+		ch <- prometheus.MustNewConstMetric(spec.Desc(), prometheus.GaugeValue, boolValue(v == current), v)
+	}
+	if !isKnown {
+		ch <- prometheus.MustNewConstMetric(spec.Desc(), prometheus.GaugeValue, 1, current)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
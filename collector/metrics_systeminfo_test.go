@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+// scrapeCount runs the scraper and returns the number of the metrics of every spec.
+func scrapeCount(scraper Scraper, client *HarborClient) (map[*MetricSpec]int, error) {
+	ch := make(chan prometheus.Metric)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- scraper.Scrape(client, ch)
+		close(ch)
+	}()
+
+	specs := scraper.(MetricScraper).Metrics()
+	counts := make(map[*MetricSpec]int)
+	for m := range ch {
+		for _, s := range specs {
+			if m.Desc() == s.Desc() {
+				counts[s]++
+			}
+		}
+	}
+	return counts, <-errCh
+}
+
+func TestSystemInfoWithoutRegistryURL(t *testing.T) {
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the anonymous /systeminfo of some versions doesn't answer the registry_url
+		io.WriteString(w, `{"harbor_version":"v2.1.0","auth_mode":"oidc_auth","read_only":true,"with_notary":true}`)
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api/v2.0"
+	counts, err := scrapeCount(ScrapeSystemInfo{}, newTestClient(t, opts))
+	if err == nil {
+		t.Error("the missing registry_url is not an error")
+	}
+	for spec, want := range map[*MetricSpec]int{
+		systemReadOnly: 1,
+		systemAuthMode: len(authModes),
+		systemFeature:  len(systemFeatures(&harborclient.SystemInfo{}, true)),
+		harborInfo:     0,
+		systemInfo:     0,
+	} {
+		if counts[spec] != want {
+			t.Errorf("%s is sent %d times, want %d", spec.Name, counts[spec], want)
+		}
+	}
+}
+
+func TestSystemInfoWithoutReadOnly(t *testing.T) {
+	for _, c := range []struct {
+		name, body string
+		want       int
+	}{
+		{"answered", `{"harbor_version":"v1.10.3","registry_url":"harbor.dev","read_only":false}`, 1},
+		{"absent", `{"harbor_version":"v1.10.3","registry_url":"harbor.dev"}`, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, c.body)
+			}))
+			defer harbor.Close()
+
+			opts := DefaultHarborOpts()
+			opts.Url = harbor.URL + "/api"
+			counts, err := scrapeCount(ScrapeSystemInfo{}, newTestClient(t, opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counts[systemReadOnly] != c.want {
+				t.Errorf("%s is sent %d times, want %d", systemReadOnly.Name, counts[systemReadOnly], c.want)
+			}
+		})
+	}
+}
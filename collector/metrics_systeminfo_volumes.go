@@ -1,22 +1,23 @@
 package collector
 
 import (
-	"encoding/json"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // check interface
-var _ Scraper = ScrapeQuotas{}
+var _ PermissionScraper = ScrapeQuotas{}
+var _ VersionedScraper = ScrapeQuotas{}
+var _ MetricScraper = ScrapeQuotas{}
 
 const (
 	volumesUrl = "/systeminfo/volumes"
 )
 
 var (
-	systemVolumes = prometheus.NewDesc(
+	systemVolumes = newMetricSpec(
 		prometheus.BuildFQName(namespace, "", "system_volumes_bytes"),
-		"Get system volume info (total/free size).", []string{"type"}, nil)
+		"Get system volume info (total/free size).", prometheus.GaugeValue, "type")
 )
 
 type ScrapeQuotas struct{}
@@ -31,40 +32,43 @@ func (ScrapeQuotas) Help() string {
 	return "Collect the systeminfoVolumes, user must have admin"
 }
 
+// Metrics exposed by the Scraper.
+func (ScrapeQuotas) Metrics() []*MetricSpec {
+	return []*MetricSpec{systemVolumes}
+}
+
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeQuotas) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
+
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeQuotas) SupportedVersions() VersionRange {
+	return VersionRange{Min: "1.1.0"}
+}
+
 // Scrape collects data from client and sends it over channel as prometheus metric.
 func (ScrapeQuotas) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data quotasJson
-	body, err := client.request(volumesUrl)
+	data, err := client.api().SystemVolumes()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
 	if data.Storage.Total == 0 {
 		return errors.Wrap(resultErr, volumesUrl)
 	}
 
 	ch <- prometheus.MustNewConstMetric(
-		systemVolumes, prometheus.GaugeValue, data.Storage.Total, "total",
+		systemVolumes.Desc(), prometheus.GaugeValue, data.Storage.Total, "total",
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		systemVolumes, prometheus.GaugeValue, data.Storage.Free, "free",
+		systemVolumes.Desc(), prometheus.GaugeValue, data.Storage.Free, "free",
 	)
 
 	ch <- prometheus.MustNewConstMetric(
-		systemVolumes, prometheus.GaugeValue, data.Storage.Total-data.Storage.Free, "used",
+		systemVolumes.Desc(), prometheus.GaugeValue, data.Storage.Total-data.Storage.Free, "used",
 	)
 
 	return nil
 }
-
-type quotasJson struct {
-	Storage struct {
-		Total float64 `json:"total"`
-		Free  float64 `json:"free"`
-	} `json:"storage"`
-}
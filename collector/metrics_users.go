@@ -1,31 +1,20 @@
 package collector
 
 import (
-	"encoding/json"
-	"fmt"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
 )
 
 // check interface
-var _ Scraper = ScrapeUsers{}
+var _ PermissionScraper = ScrapeUsers{}
+var _ VersionedScraper = ScrapeUsers{}
+var _ MetricScraper = ScrapeUsers{}
 
 const (
 	usersUrl = "/users"
 )
 
-var (
-	usersRefInfo = prometheus.NewDesc(
-		prometheus.BuildFQName(namespace, "ref_work", "users"),
-		"test the users api ref work status(0 for error, 1 for success).",
-		[]string{"ref", "method"}, nil,
-	)
-)
-
-type usersJson struct {
-	UID int `json:"user_id"`
-}
-
 type ScrapeUsers struct{}
 
 // Name of the Scraper. Should be unique.
@@ -38,86 +27,77 @@ func (ScrapeUsers) Help() string {
 	return "Collect the users api work, user have admin role"
 }
 
-// Scrape collects data from client and sends it over channel as prometheus metric.
-func (s ScrapeUsers) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var err error
+// Metrics exposed by the Scraper.
+func (ScrapeUsers) Metrics() []*MetricSpec {
+	return probeMetrics("users")
+}
 
-	err = users(client, ch)
-	if err != nil {
-		return err
-	}
+// RequiredPermissions of the Scraper, it is skipped when the user lacks them.
+func (ScrapeUsers) RequiredPermissions() []Permission {
+	return []Permission{PermissionSysAdmin}
+}
 
-	err = userCurrent(client, ch)
-	if err != nil {
-		return err
+// SupportedVersions of the Scraper, it is skipped on the other harbor versions.
+func (ScrapeUsers) SupportedVersions() VersionRange {
+	return VersionRange{
+		// page_size of /users doesn't work
+		Broken: []string{"1.5.1"},
 	}
+}
 
-	return nil
+// Scrape collects data from client and sends it over channel as prometheus metric.
+func (s ScrapeUsers) Scrape(client *HarborClient, ch chan<- prometheus.Metric) error {
+	return firstError(users(client, ch), userCurrent(client, ch))
 }
 
 func users(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var data []usersJson
-	url := usersUrl + "?page_size=1"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err = json.Unmarshal(body, &data); err != nil {
-		return err
-	}
-
-	if len(data) != 1 || data[0].UID == 0 {
-		return fmt.Errorf("cannot find a user id by %s", url)
-	}
-
-	ch <- prometheus.MustNewConstMetric(usersRefInfo, prometheus.GaugeValue,
-		1, usersUrl, "GET")
-
-	var result usersJson
-
-	url = usersUrl + "/" + strconv.Itoa(data[0].UID)
-	body, err = client.request(url)
+	var data []harborclient.User
+	err := client.probe(ch, "users", usersUrl, func() (err error) {
+		data, err = client.api().Users(harborclient.ListOptions{PageSize: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(data) != 1 || data[0].UserID == 0 {
+			return errors.Wrapf(resultErr, "cannot find a user id by %s?page_size=1", usersUrl)
+		}
+
+		return nil
+	})
 	if err != nil {
+		client.skipProbes(ch, "users", "/users/{user_id}")
 		return err
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return err
-	}
-
-	if result.UID == 0 {
-		return fmt.Errorf("cannot find the user by %s", url)
-	}
+	return client.probe(ch, "users", "/users/{user_id}", func() error {
+		result, err := client.api().User(data[0].UserID)
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(usersRefInfo, prometheus.GaugeValue,
-		1, "/users/{user_id}", "GET")
+		if result.UserID == 0 {
+			return errors.Wrapf(resultErr, "cannot find the user by %s/%d", usersUrl, data[0].UserID)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func userCurrent(client *HarborClient, ch chan<- prometheus.Metric) error {
-	var result usersJson
-
 	url := usersUrl + "/current"
-	body, err := client.request(url)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return err
-	}
-
-	if result.UID == 0 {
-		return fmt.Errorf("cannot find the current info by %s", url)
-	}
+	return client.probe(ch, "users", url, func() error {
+		result, err := client.api().CurrentUser()
+		if err != nil {
+			return err
+		}
 
-	ch <- prometheus.MustNewConstMetric(usersRefInfo, prometheus.GaugeValue,
-		1, url, "GET")
+		if result.UserID == 0 {
+			return errors.Wrapf(resultErr, "cannot find the current info by %s", url)
+		}
 
-	// TODO
-	//  /users/current/permissions will be [] default
+		// TODO
+		//  /users/current/permissions will be [] default
 
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Option configures the Exporter created by NewExporter.
+type Option func(*config)
+
+type config struct {
+	opts       *HarborOpts         // copied by NewExporter, never changed
+	fields     []func(*HarborOpts) // applied on the copy of opts whatever the order of the options
+	httpClient *http.Client
+	auth       Authenticator
+	metrics    *Metrics
+	scrapers   []Scraper
+	limiter    *Limiter
+	tracer     Tracer
+	logger     log.FieldLogger
+}
+
+// WithHarborOpts replaces the DefaultHarborOpts, e.g. the opts filled by AddFlags,
+// the Exporter works on a copy so opts could be reused for the other Exporters.
+func WithHarborOpts(opts *HarborOpts) Option {
+	return func(c *config) {
+		c.opts = opts
+	}
+}
+
+// WithURL sets the API address of harbor, e.g. https://harbor.dev/api.
+// It overrides the one of WithHarborOpts given before or after it.
+func WithURL(url string) Option {
+	return withField(func(o *HarborOpts) {
+		o.Url = url
+	})
+}
+
+// WithBasicAuth sets the harbor user and password,
+// it overrides the ones of WithHarborOpts given before or after it.
+func WithBasicAuth(username, password string) Option {
+	return withField(func(o *HarborOpts) {
+		o.Username = username
+		o.SetPassword(password)
+	})
+}
+
+func withField(set func(*HarborOpts)) Option {
+	return func(c *config) {
+		c.fields = append(c.fields, set)
+	}
+}
+
+// WithAuthenticator authenticates the requests to harbor by auth instead of the auth mode of the HarborOpts.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(c *config) {
+		c.auth = auth
+	}
+}
+
+// WithHTTPClient sends the requests to harbor by client,
+// the timeout and the TLS settings of the HarborOpts are ignored then.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithMetrics carries the exporter metrics over the Exporters, default NewMetrics().
+func WithMetrics(metrics Metrics) Option {
+	return func(c *config) {
+		c.metrics = &metrics
+	}
+}
+
+// WithScrapers sets the enabled scrapers in the order they run,
+// default the ones enabled by default in the DefaultRegistry.
+func WithScrapers(scrapers ...Scraper) Option {
+	return func(c *config) {
+		c.scrapers = scrapers
+	}
+}
+
+// WithGlobalLimiter limits the requests by l as well as by the limits of the HarborOpts,
+// give the same Limiter to the Exporters of several harbors to limit them together.
+func WithGlobalLimiter(l *Limiter) Option {
+	return func(c *config) {
+		c.limiter = l
+	}
+}
+
+func WithTracer(t Tracer) Option {
+	return func(c *config) {
+		c.tracer = t
+	}
+}
+
+// WithLogger logs by l instead of the logrus standard logger.
+func WithLogger(l log.FieldLogger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptionsOrder(t *testing.T) {
+	base := DefaultHarborOpts()
+	base.Url = "http://base.dev/api"
+	base.SetPassword("base-password")
+	base.PingStrategy = ""
+	base.Headers = []string{"X-Tenant=a"}
+	before := *base
+
+	for _, options := range [][]Option{
+		{WithURL("http://harbor.dev/api"), WithBasicAuth("robot", "secret"), WithHarborOpts(base)},
+		{WithHarborOpts(base), WithURL("http://harbor.dev/api"), WithBasicAuth("robot", "secret")},
+	} {
+		e, err := NewExporter(options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := e.client.Opts
+		if opts == base {
+			t.Fatal("the Exporter works on the opts of the caller")
+		}
+		if opts.Url != "http://harbor.dev/api" || opts.Username != "robot" || opts.password != "secret" {
+			t.Errorf("url and user are %s %s %s, want the ones of WithURL and WithBasicAuth", opts.Url, opts.Username, opts.password)
+		}
+		if opts.PingStrategy != PingStrategyAuto || opts.header.Get("X-Tenant") != "a" {
+			t.Errorf("the opts of WithHarborOpts are not applied: %+v", opts)
+		}
+		if opts.Redactor() != base.Redactor() {
+			t.Error("the Exporter doesn't share the Redactor of the caller")
+		}
+	}
+
+	if !reflect.DeepEqual(*base, before) {
+		t.Errorf("NewExporter changed the opts of the caller to %+v, want %+v", *base, before)
+	}
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+var (
+	// PermissionSysAdmin stands for the system admin role, which is granted every permission.
+	PermissionSysAdmin = Permission{Resource: "*", Action: "sysadmin"}
+)
+
+const (
+	SkipReasonPermission = "permission"
+)
+
+// Permission is a resource and action pair of the harbor RBAC, e.g. {"/project/1/repository", "pull"}.
+type Permission = harborclient.Permission
+
+// PermissionScraper is implemented by the scrapers which need more than a valid user.
+type PermissionScraper interface {
+	Scraper
+	RequiredPermissions() []Permission
+}
+
+// accessInfo is what the current user could access in harbor,
+// it is discovered once and refreshed every --permission-refresh-interval.
+type accessInfo struct {
+	mu          sync.RWMutex
+	checked     time.Time
+	known       bool
+	sysAdmin    bool
+	permissions []Permission
+}
+
+// refreshAccess discovers the sysadmin flag and the permissions of the current user
+// if the last discovery is older than the refresh interval.
+func (h *HarborClient) refreshAccess(force bool) error {
+	h.access.mu.RLock()
+	fresh := h.access.known && time.Since(h.access.checked) < h.Opts.PermissionRefreshInterval
+	h.access.mu.RUnlock()
+	if fresh && !force {
+		return nil
+	}
+
+	if h.Opts.AuthMode == AuthModeRobot {
+		// the robot accounts are not users, /users/current answers 401 to them,
+		// and they are never the system admin
+		h.access.mu.Lock()
+		h.access.checked = time.Now()
+		h.access.known = true
+		h.access.sysAdmin = false
+		h.access.permissions = nil
+		h.access.mu.Unlock()
+		return nil
+	}
+
+	user, err := h.api().CurrentUser()
+	if err != nil {
+		return err
+	}
+
+	// /users/current/permissions is [] without scope on some versions,
+	// that only means nothing more than the sysadmin flag could be known.
+	permissions, err := h.api().CurrentUserPermissions()
+	if err != nil {
+		h.log().WithField("url", usersUrl+"/current/permissions").Debug(err)
+	}
+
+	sysAdmin := user.IsSysAdmin()
+
+	h.access.mu.Lock()
+	h.access.checked = time.Now()
+	h.access.known = true
+	h.access.sysAdmin = sysAdmin
+	h.access.permissions = permissions
+	h.access.mu.Unlock()
+
+	h.log().WithFields(log.Fields{
+		"sysadmin":    sysAdmin,
+		"permissions": len(permissions),
+	}).Debug("discovered the permissions of the current user")
+
+	return nil
+}
+
+// missingPermissions returns the permissions the current user lacks for the scraper,
+// nothing is missing while the permissions are unknown.
+func (h *HarborClient) missingPermissions(scraper Scraper) []Permission {
+	ps, ok := scraper.(PermissionScraper)
+	if !ok {
+		return nil
+	}
+
+	h.access.mu.RLock()
+	defer h.access.mu.RUnlock()
+
+	if !h.access.known || h.access.sysAdmin {
+		return nil
+	}
+
+	var missing []Permission
+	for _, required := range ps.RequiredPermissions() {
+		if !hasPermission(h.access.permissions, required) {
+			missing = append(missing, required)
+		}
+	}
+	return missing
+}
+
+func hasPermission(granted []Permission, required Permission) bool {
+	if required == PermissionSysAdmin {
+		return false
+	}
+	for _, p := range granted {
+		if p == required {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPermissions(permissions []Permission) string {
+	s := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		s = append(s, p.Resource+":"+p.Action)
+	}
+	return fmt.Sprint(s)
+}
@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zhangguanzhang/harbor_exporter/harborclient"
+)
+
+// reasons of a failed Ping, exposed by harbor_exporter_ping_failure_reason
+const (
+	PingReasonNetwork     = "network"
+	PingReasonTLS         = "tls"
+	PingReasonAuth        = "auth"
+	PingReasonPermission  = "permission"
+	PingReasonServerError = "server_error"
+	PingReasonUnknown     = "unknown"
+)
+
+var pingReasons = []string{
+	PingReasonNetwork,
+	PingReasonTLS,
+	PingReasonAuth,
+	PingReasonPermission,
+	PingReasonServerError,
+	PingReasonUnknown,
+}
+
+// strategies of Ping, all but configurations work without credentials
+const (
+	// PingStrategyAuto tries the v2 /ping and falls back to /systeminfo
+	PingStrategyAuto           = "auto"
+	PingStrategySystemInfo     = "systeminfo"
+	PingStrategyConfigurations = "configurations"
+)
+
+// PingError is returned by Ping and CheckAuth with the classified reason of the failure.
+type PingError struct {
+	Endpoint string
+	Code     int // 0 if there is no response
+	Reason   string
+	Err      error
+}
+
+func (e *PingError) Error() string {
+	return fmt.Sprintf("ping harbor %s failed (%s): %s", e.Endpoint, e.Reason, e.Err)
+}
+
+func (e *PingError) Unwrap() error {
+	return e.Err
+}
+
+// PingFailureReason returns the reason of a Ping error, empty if err is nil.
+func PingFailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	var pe *PingError
+	if errors.As(err, &pe) {
+		return pe.Reason
+	}
+	return PingReasonUnknown
+}
+
+// Ping checks whether harbor is alive by the endpoints of the ping strategy,
+// only the configurations strategy needs the credentials.
+func (h *HarborClient) Ping() (bool, error) {
+	switch h.Opts.PingStrategy {
+	case PingStrategyConfigurations:
+		return h.ping(configurationsUrl, true)
+	case PingStrategySystemInfo:
+		return h.ping(systemInfoUrl, false)
+	default:
+		pong, err := h.ping("/ping", false)
+		var pe *PingError
+		// v1 harbor doesn't have the /ping
+		if errors.As(err, &pe) && pe.Code == http.StatusNotFound {
+			return h.ping(systemInfoUrl, false)
+		}
+		return pong, err
+	}
+}
+
+// CheckAuth validates the credentials by the current user api,
+// which any valid account could access.
+func (h *HarborClient) CheckAuth() (bool, error) {
+	if h.Opts.AuthMode == AuthModeRobot {
+		// the robot accounts are not users, /users/current answers 401 to them
+		return h.pingCheck(projectsUrl+"?public=false&page_size=1", true, checkRobotProjects)
+	}
+	return h.ping(usersUrl+"/current", true)
+}
+
+// checkRobotProjects tells a robot from the anonymous user by the private projects of /projects?public=false,
+// harbor treats the refused credentials as anonymous and answers 200 with the public projects or none.
+func checkRobotProjects(body []byte) error {
+	var projects []harborclient.Project
+	if err := json.Unmarshal(body, &projects); err != nil {
+		return fmt.Errorf("decode the projects: %s", err)
+	}
+	if len(projects) == 0 || projects[0].Metadata["public"] == "true" {
+		return errors.New("the robot credentials are treated as anonymous, or the robot has no private project")
+	}
+	return nil
+}
+
+func (h *HarborClient) ping(endpoint string, auth bool) (bool, error) {
+	return h.pingCheck(endpoint, auth, nil)
+}
+
+// pingCheck requests the endpoint, and checks the body by check if harbor answered 200,
+// the check errors are auth failures.
+func (h *HarborClient) pingCheck(endpoint string, auth bool, check func(body []byte) error) (_ bool, err error) {
+	span := h.startSpan("ping " + endpoint)
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.url", h.Opts.Url+endpoint)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	resp, err := h.do(endpoint, auth, nil)
+	if err != nil {
+		var se *harborclient.StatusError
+		if errors.As(err, &se) {
+			return false, statusPingError(endpoint, se.Code, se.Status)
+		}
+		return false, &PingError{Endpoint: endpoint, Reason: transportFailureReason(err), Err: err}
+	}
+
+	defer resp.Body.Close()
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode == http.StatusOK {
+		if check == nil {
+			return true, nil
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return false, &PingError{Endpoint: endpoint, Reason: transportFailureReason(err), Err: err}
+		}
+		if err := check(body); err != nil {
+			return false, &PingError{Endpoint: endpoint, Code: resp.StatusCode, Reason: PingReasonAuth, Err: err}
+		}
+		return true, nil
+	}
+	return false, statusPingError(endpoint, resp.StatusCode, resp.Status)
+}
+
+// statusPingError classifies the status code harbor answered other than 200.
+func statusPingError(endpoint string, code int, status string) *PingError {
+	pe := &PingError{Endpoint: endpoint, Code: code}
+	switch {
+	case code == http.StatusUnauthorized:
+		pe.Reason, pe.Err = PingReasonAuth, errors.New("username or password incorrect")
+	case code == http.StatusForbidden:
+		pe.Reason, pe.Err = PingReasonPermission, errors.New("user has no permission to the ping endpoint")
+	case code >= http.StatusInternalServerError:
+		pe.Reason, pe.Err = PingReasonServerError, fmt.Errorf("http-statuscode: %s", status)
+	default:
+		pe.Reason, pe.Err = PingReasonUnknown, fmt.Errorf("error handling request, http-statuscode: %s", status)
+	}
+	return pe
+}
+
+// transportFailureReason tells the TLS handshake failures from the other network errors,
+// and the credentials which could not be sent, e.g. a missing token file.
+func transportFailureReason(err error) string {
+	var (
+		urlErr           *url.Error
+		authErr          *authenticateError
+		unknownAuthority x509.UnknownAuthorityError
+		certInvalid      x509.CertificateInvalidError
+		hostname         x509.HostnameError
+		recordHeader     tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &authErr) && !errors.As(err, &urlErr):
+		return PingReasonAuth
+	case errors.As(err, &unknownAuthority),
+		errors.As(err, &certInvalid),
+		errors.As(err, &hostname),
+		errors.As(err, &recordHeader):
+		return PingReasonTLS
+	// the alerts from the server are not exported by crypto/tls
+	case strings.Contains(err.Error(), "tls: "):
+		return PingReasonTLS
+	default:
+		return PingReasonNetwork
+	}
+}
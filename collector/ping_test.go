@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckRobotAuth(t *testing.T) {
+	// harbor treats the refused credentials as anonymous, which sees the public projects only
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/projects" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user == "robot$monitor" && pass == "secret" && r.URL.Query().Get("public") == "false" {
+			io.WriteString(w, `[{"project_id":2,"name":"private","metadata":{"public":"false"}}]`)
+			return
+		}
+		if r.URL.Query().Get("public") == "false" {
+			io.WriteString(w, `[]`)
+			return
+		}
+		io.WriteString(w, `[{"project_id":1,"name":"library","metadata":{"public":"true"}}]`)
+	}))
+	defer harbor.Close()
+
+	for _, c := range []struct {
+		password string
+		valid    bool
+	}{
+		{"secret", true},
+		{"revoked", false},
+	} {
+		opts := DefaultHarborOpts()
+		opts.Url = harbor.URL + "/api"
+		opts.AuthMode = AuthModeRobot
+		opts.Username = "monitor"
+		opts.SetPassword(c.password)
+		valid, err := newTestClient(t, opts).CheckAuth()
+		if valid != c.valid {
+			t.Errorf("the robot secret %s is valid %v (%v), want %v", c.password, valid, err, c.valid)
+		}
+		if !c.valid && PingFailureReason(err) != PingReasonAuth {
+			t.Errorf("the reason of the refused robot is %q, want %s", PingFailureReason(err), PingReasonAuth)
+		}
+	}
+}
+
+func TestCheckRobotAuthPublicProjects(t *testing.T) {
+	// harbor v1 answers the public projects to the anonymous user whatever the public filter
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"project_id":1,"name":"library","metadata":{"public":"true"}}]`)
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.AuthMode = AuthModeRobot
+	opts.SetPassword("revoked")
+	if valid, err := newTestClient(t, opts).CheckAuth(); valid || PingFailureReason(err) != PingReasonAuth {
+		t.Errorf("the anonymous answer is valid %v (%v), want an auth failure", valid, err)
+	}
+}
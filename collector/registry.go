@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Cost is how heavy a scraper is on harbor, by the number of the requests of one scrape.
+type Cost string
+
+const (
+	CostCheap     Cost = "cheap"     // one request
+	CostModerate  Cost = "moderate"  // a few requests
+	CostExpensive Cost = "expensive" // many requests, or the requests walk the projects
+)
+
+// ScraperInfo is a registered scraper and its metadata.
+type ScraperInfo struct {
+	Scraper          Scraper
+	EnabledByDefault bool
+	Cost             Cost
+}
+
+func (i ScraperInfo) Name() string {
+	return i.Scraper.Name()
+}
+
+func (i ScraperInfo) Help() string {
+	return i.Scraper.Help()
+}
+
+// RequiredPermissions of the scraper, empty if any valid user could run it.
+func (i ScraperInfo) RequiredPermissions() []Permission {
+	if ps, ok := i.Scraper.(PermissionScraper); ok {
+		return ps.RequiredPermissions()
+	}
+	return nil
+}
+
+// SupportedVersions of the scraper, the zero VersionRange means all.
+func (i ScraperInfo) SupportedVersions() VersionRange {
+	if vs, ok := i.Scraper.(VersionedScraper); ok {
+		return vs.SupportedVersions()
+	}
+	return VersionRange{}
+}
+
+// Registry is the catalog of the scrapers, ordered by name.
+type Registry struct {
+	infos []ScraperInfo
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry returns a new Registry with all the scrapers of this package.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister(ScrapeConfigurations{}, false, CostCheap)
+	r.MustRegister(ScrapeGc{}, false, CostCheap)
+	r.MustRegister(ScrapeHealth{}, true, CostCheap)
+	r.MustRegister(ScrapeLables{}, false, CostCheap)
+	r.MustRegister(ScrapeLogs{}, true, CostCheap)
+	r.MustRegister(ScrapeProjects{}, true, CostExpensive)
+	r.MustRegister(ScrapeQuotas{}, true, CostCheap)
+	r.MustRegister(ScrapeRegistries{}, false, CostCheap)
+	r.MustRegister(ScrapeReplication{}, false, CostModerate)
+	r.MustRegister(ScrapeStatistics{}, true, CostCheap)
+	r.MustRegister(ScrapeSystemInfo{}, true, CostCheap)
+	r.MustRegister(ScrapeUsers{}, true, CostModerate)
+	return r
+}
+
+// Register adds the scraper, the name must be unique.
+func (r *Registry) Register(scraper Scraper, enabledByDefault bool, cost Cost) error {
+	name := scraper.Name()
+	i := sort.Search(len(r.infos), func(i int) bool { return r.infos[i].Name() >= name })
+	if i < len(r.infos) && r.infos[i].Name() == name {
+		return fmt.Errorf("scraper %q is already registered", name)
+	}
+
+	r.infos = append(r.infos, ScraperInfo{})
+	copy(r.infos[i+1:], r.infos[i:])
+	r.infos[i] = ScraperInfo{Scraper: scraper, EnabledByDefault: enabledByDefault, Cost: cost}
+	return nil
+}
+
+// MustRegister is Register but panics on the error.
+func (r *Registry) MustRegister(scraper Scraper, enabledByDefault bool, cost Cost) {
+	if err := r.Register(scraper, enabledByDefault, cost); err != nil {
+		panic(err)
+	}
+}
+
+// Scrapers returns the registered scrapers ordered by name.
+func (r *Registry) Scrapers() []ScraperInfo {
+	return append([]ScraperInfo(nil), r.infos...)
+}
+
+// Lookup returns the scraper registered as name.
+func (r *Registry) Lookup(name string) (ScraperInfo, bool) {
+	i := sort.Search(len(r.infos), func(i int) bool { return r.infos[i].Name() >= name })
+	if i < len(r.infos) && r.infos[i].Name() == name {
+		return r.infos[i], true
+	}
+	return ScraperInfo{}, false
+}
+
+// Defaults returns the scrapers enabled by default ordered by name.
+func (r *Registry) Defaults() []Scraper {
+	scrapers := []Scraper{}
+	for _, info := range r.infos {
+		if info.EnabledByDefault {
+			scrapers = append(scrapers, info.Scraper)
+		}
+	}
+	return scrapers
+}
+
+// WriteCatalog writes the scrapers and their metadata as a table, e.g. for --list-collectors.
+func (r *Registry) WriteCatalog(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDEFAULT\tCOST\tPERMISSIONS\tVERSIONS\tHELP")
+	for _, info := range r.infos {
+		permissions := "-"
+		if ps := info.RequiredPermissions(); len(ps) != 0 {
+			permissions = strings.Trim(formatPermissions(ps), "[]")
+		}
+		enabled := "disabled"
+		if info.EnabledByDefault {
+			enabled = "enabled"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Name(), enabled, info.Cost, permissions, info.SupportedVersions(), info.Help())
+	}
+	return tw.Flush()
+}
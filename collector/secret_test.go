@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+)
+
+// headerAuth is an Authenticator of an auth gateway which doesn't use the password.
+type headerAuth struct{}
+
+func (headerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("X-Gateway-Token", "token")
+	return nil
+}
+
+func TestDefaultPassword(t *testing.T) {
+	// the example of the README
+	limiter := NewLimiter(20, 0, 10)
+	for _, host := range []string{"https://a.harbor.dev/api", "https://b.harbor.dev/api"} {
+		if _, err := NewExporter(WithURL(host), WithGlobalLimiter(limiter)); err != nil {
+			t.Errorf("the example of the README fails: %s", err)
+		}
+	}
+
+	for _, c := range []struct {
+		name    string
+		args    []string
+		env     string
+		options []Option
+		refused bool
+	}{
+		{name: "flag default", refused: true},
+		{name: "flag set", args: []string{"--harbor-pass=Harbor12345"}},
+		{name: "flag set to the default", args: []string{"--harbor-pass=password"}},
+		{name: "env", env: "Harbor12345"},
+		{name: "allowed", args: []string{"--allow-default-password"}},
+		{name: "bearer", args: []string{"--harbor-auth-mode=bearer", "--harbor-token-file=/dev/null"}},
+		{name: "authenticator", options: []Option{WithAuthenticator(headerAuth{})}},
+		{name: "basic auth option", options: []Option{WithBasicAuth("admin", "Harbor12345")}},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts := DefaultHarborOpts()
+		opts.AddFlags(fs)
+		if err := fs.Parse(append([]string{"--harbor-server=https://harbor.dev/api"}, c.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if c.env != "" {
+			opts.SetPassword(c.env)
+		}
+
+		_, err := NewExporter(append([]Option{WithHarborOpts(opts)}, c.options...)...)
+		refused := err != nil && strings.Contains(err.Error(), "default password")
+		if refused != c.refused {
+			t.Errorf("%s: refused %v (%v), want %v", c.name, refused, err, c.refused)
+		}
+	}
+}
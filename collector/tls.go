@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tlsReloadCheckInterval is how often the TLS files are checked for changes at most.
+const tlsReloadCheckInterval = 5 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// caDirFiles returns the files in the CA dir, sorted.
+func (o *HarborOpts) caDirFiles() ([]string, error) {
+	if o.TLSCADir == "" {
+		return nil, nil
+	}
+	entries, err := ioutil.ReadDir(o.TLSCADir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, filepath.Join(o.TLSCADir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// tlsFiles returns the files the TLS config is built from, sorted.
+func (o *HarborOpts) tlsFiles() ([]string, error) {
+	files, err := o.caDirFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []string{o.TLSCAFile, o.TLSCertFile, o.TLSKeyFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// tlsConfig builds the TLS config to harbor from the opts and the files they point to.
+func (o *HarborOpts) tlsConfig() (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if o.TLSMinVersion != "" {
+		v, ok := tlsVersions[strings.ToUpper(o.TLSMinVersion)]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS min version: %s", o.TLSMinVersion)
+		}
+		minVersion = v
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.TLSCAFile)
+		}
+	}
+	caDirFiles, err := o.caDirFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range caDirFiles {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		// the CA dir may contain the other files, e.g. a README or the hash links of c_rehash
+		rootCAs.AppendCertsFromPEM(pem)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         minVersion,
+		RootCAs:            rootCAs,
+		ServerName:         o.TLSServerName,
+		InsecureSkipVerify: o.Insecure,
+	}
+
+	switch {
+	case o.TLSCertFile != "" && o.TLSKeyFile != "":
+		cert, err := tls.LoadX509KeyPair(o.TLSCertFile, o.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case o.TLSCertFile != "" || o.TLSKeyFile != "":
+		return nil, fmt.Errorf("both the client certificate and key are required for mTLS")
+	}
+
+	return cfg, nil
+}
+
+// reloadingTransport rebuilds the transport to harbor when the TLS files change on disk,
+// e.g. the renewed client certificate, the changes are checked lazily on the requests.
+type reloadingTransport struct {
+	opts    *HarborOpts
+	logger  log.FieldLogger
+	newBase func(*tls.Config) *http.Transport
+
+	mu          sync.Mutex
+	base        *http.Transport
+	fingerprint string
+	checked     time.Time
+}
+
+func newReloadingTransport(opts *HarborOpts, logger log.FieldLogger, newBase func(*tls.Config) *http.Transport) (*reloadingTransport, error) {
+	t := &reloadingTransport{opts: opts, logger: logger, newBase: newBase}
+	fingerprint, err := t.fingerprintFiles()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.base = newBase(cfg)
+	t.fingerprint = fingerprint
+	t.checked = time.Now()
+	return t, nil
+}
+
+// fingerprintFiles identifies the current content of the TLS files by their size and mtime.
+func (t *reloadingTransport) fingerprintFiles() (string, error) {
+	files, err := t.opts.tlsFiles()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", f, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func (t *reloadingTransport) transport() *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.checked) < tlsReloadCheckInterval {
+		return t.base
+	}
+	t.checked = time.Now()
+
+	fingerprint, err := t.fingerprintFiles()
+	if err != nil || fingerprint == t.fingerprint {
+		if err != nil {
+			t.logger.WithError(err).Warn("check the TLS files, keep the loaded ones")
+		}
+		return t.base
+	}
+
+	cfg, err := t.opts.tlsConfig()
+	if err != nil {
+		t.logger.WithError(err).Warn("reload the TLS files, keep the loaded ones")
+		return t.base
+	}
+	t.logger.Info("reloaded the TLS files")
+
+	old := t.base
+	t.base = t.newBase(cfg)
+	t.fingerprint = fingerprint
+	old.CloseIdleConnections()
+	return t.base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *reloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport().RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the current transport.
+func (t *reloadingTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.base.CloseIdleConnections()
+}
@@ -0,0 +1,47 @@
+package collector
+
+// Tracer starts the spans of the scrapes and the requests to harbor,
+// e.g. the OTLP tracer of the otlp package.
+type Tracer interface {
+	// Start starts a span, a nil parent starts a new trace.
+	Start(parent Span, name string) Span
+}
+
+// Span is a traced operation, End must be called once.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	SetError(err error)
+	End()
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(Span, string) Span { return noopSpan{} }
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) SetError(error)                   {}
+func (noopSpan) End()                             {}
+
+// SetTracer traces every scrape, with the spans of each Scraper.Scrape and each request to harbor.
+func (e *Exporter) SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	e.client.tracer = t
+}
+
+// withSpan returns a shallow copy of the client whose requests are traced as the children of span.
+func (h *HarborClient) withSpan(span Span) *HarborClient {
+	c := *h
+	c.span = span
+	return &c
+}
+
+func (h *HarborClient) startSpan(name string) Span {
+	if h.tracer == nil {
+		return noopSpan{}
+	}
+	return h.tracer.Start(h.span, name)
+}
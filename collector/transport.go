@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyFromEnvironment is the ProxyURL using the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env vars.
+const ProxyFromEnvironment = "env"
+
+// proxyFunc returns the Proxy of the transport, nil for the direct connections.
+func (o *HarborOpts) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch o.ProxyURL {
+	case "":
+		return nil, nil
+	case ProxyFromEnvironment:
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(o.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %s, the scheme must be http, https, socks5 or socks5h", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s, missing the host", u.Redacted())
+	}
+	return http.ProxyURL(u), nil
+}
+
+// parseHeaders parses the key=value headers.
+func parseHeaders(headers []string) (http.Header, error) {
+	h := http.Header{}
+	for _, kv := range headers {
+		s := strings.SplitN(kv, "=", 2)
+		if len(s) != 2 || strings.TrimSpace(s[0]) == "" {
+			return nil, fmt.Errorf("invalid harbor header %q, must be key=value", kv)
+		}
+		h.Add(strings.TrimSpace(s[0]), s[1])
+	}
+	return h, nil
+}
+
+// parseResolve parses the host=addr or host:port=addr[:port] overrides of the DNS.
+func parseResolve(resolve []string) (map[string]string, error) {
+	m := make(map[string]string, len(resolve))
+	for _, kv := range resolve {
+		s := strings.SplitN(kv, "=", 2)
+		if len(s) != 2 || s[0] == "" || s[1] == "" {
+			return nil, fmt.Errorf("invalid harbor resolve %q, must be host=addr or host:port=addr:port", kv)
+		}
+		m[strings.ToLower(s[0])] = s[1]
+	}
+	return m, nil
+}
+
+// resolveAddr returns the address to dial for addr by the resolve map,
+// host:port is looked up before host, the port is kept if the override has none.
+func resolveAddr(resolve map[string]string, addr string) string {
+	if to, ok := resolve[strings.ToLower(addr)]; ok {
+		if _, _, err := net.SplitHostPort(to); err == nil {
+			return to
+		}
+		_, port, _ := net.SplitHostPort(addr)
+		return net.JoinHostPort(to, port)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if to, ok := resolve[strings.ToLower(host)]; ok {
+		if _, _, err := net.SplitHostPort(to); err == nil {
+			return to
+		}
+		return net.JoinHostPort(to, port)
+	}
+	return addr
+}
+
+// transportFactory validates the connection options and returns the builder of the transports,
+// called again with the reloaded TLS config.
+func (o *HarborOpts) transportFactory() (func(*tls.Config) *http.Transport, error) {
+	proxy, err := o.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	resolve, err := parseResolve(o.Resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := dialer.DialContext
+	if len(resolve) != 0 {
+		// the proxies are dialed by their own address, the TLS is still verified by the original host
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, resolveAddr(resolve, addr))
+		}
+	}
+
+	return func(cfg *tls.Config) *http.Transport {
+		t := &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dial,
+			TLSClientConfig:     cfg,
+			MaxIdleConns:        o.MaxIdleConns,
+			MaxIdleConnsPerHost: o.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     o.MaxConnsPerHost,
+			IdleConnTimeout:     o.IdleConnTimeout,
+			ForceAttemptHTTP2:   o.HTTP2,
+		}
+		if !o.HTTP2 {
+			// a non-nil empty map disables HTTP/2
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		return t
+	}, nil
+}
+
+// setHeaders sets the user agent and the extra headers on a request to harbor,
+// it is called before the credentials are set so the headers can't replace them.
+func (o *HarborOpts) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", o.UA)
+	for k, v := range o.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+}
@@ -0,0 +1,360 @@
+package collector
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns the client of an Exporter to the harbor of opts.
+func newTestClient(t *testing.T, opts *HarborOpts) *HarborClient {
+	t.Helper()
+	opts.AllowDefaultPassword = true
+	e, err := NewExporter(WithHarborOpts(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.client
+}
+
+// get sends an authenticated GET of the endpoint and returns the body.
+func get(t *testing.T, client *HarborClient, endpoint string) string {
+	t.Helper()
+	resp, err := client.do(endpoint, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %s: %s", resp.Status, body)
+	}
+	return string(body)
+}
+
+// writeCA writes the certificate of the TLS test server to a file of the dir.
+func writeCA(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := ioutil.WriteFile(path, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func port(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Port()
+}
+
+// echoHost answers the Host header of the requests.
+var echoHost = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, r.Host)
+})
+
+func TestHTTPProxy(t *testing.T) {
+	harbor := httptest.NewServer(echoHost)
+	defer harbor.Close()
+
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.ProxyURL = proxy.URL
+	get(t, newTestClient(t, opts), "/systeminfo")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := harbor.URL + "/api/systeminfo"; len(proxied) != 1 || proxied[0] != want {
+		t.Errorf("proxied %v, want [%s]", proxied, want)
+	}
+}
+
+// socks5Server is a SOCKS5 proxy without auth which records the addresses it connected to.
+type socks5Server struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	addrs []string
+}
+
+func newSocks5Server(t *testing.T) *socks5Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// greeting: version, methods
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil || head[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, head[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// request: version, connect, reserved, address type
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		n := make([]byte, 1)
+		io.ReadFull(conn, n)
+		name := make([]byte, n[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	default:
+		return
+	}
+	p := make([]byte, 2)
+	if _, err := io.ReadFull(conn, p); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(p))))
+
+	s.mu.Lock()
+	s.addrs = append(s.addrs, addr)
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	harbor := httptest.NewServer(echoHost)
+	defer harbor.Close()
+
+	for _, scheme := range []string{"socks5", "socks5h"} {
+		t.Run(scheme, func(t *testing.T) {
+			proxy := newSocks5Server(t)
+			defer proxy.ln.Close()
+
+			opts := DefaultHarborOpts()
+			opts.Url = harbor.URL + "/api"
+			opts.ProxyURL = scheme + "://" + proxy.ln.Addr().String()
+			get(t, newTestClient(t, opts), "/systeminfo")
+
+			proxy.mu.Lock()
+			defer proxy.mu.Unlock()
+			if want := harbor.Listener.Addr().String(); len(proxy.addrs) != 1 || proxy.addrs[0] != want {
+				t.Errorf("connected to %v, want [%s]", proxy.addrs, want)
+			}
+		})
+	}
+}
+
+func TestInvalidProxyURL(t *testing.T) {
+	for _, u := range []string{"ftp://proxy:21", "http://", "://x"} {
+		opts := DefaultHarborOpts()
+		opts.Url = "http://127.0.0.1/api"
+		opts.ProxyURL = u
+		opts.AllowDefaultPassword = true
+		if _, err := NewExporter(WithHarborOpts(opts)); err == nil {
+			t.Errorf("proxy URL %q is accepted", u)
+		}
+	}
+}
+
+func TestExtraHeaders(t *testing.T) {
+	var got http.Header
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api"
+	opts.SetPassword("Harbor12345")
+	opts.Headers = []string{"X-Gateway-Token=gw1", "x-tenant=a", "X-Tenant=b", "Authorization=Bearer replaced"}
+	get(t, newTestClient(t, opts), "/systeminfo")
+
+	if v := got.Get("X-Gateway-Token"); v != "gw1" {
+		t.Errorf("X-Gateway-Token is %q, want gw1", v)
+	}
+	if v := got.Values("X-Tenant"); len(v) != 2 || v[0] != "a" || v[1] != "b" {
+		t.Errorf("X-Tenant is %q, want [a b]", v)
+	}
+	// the credentials are set after the headers
+	req := &http.Request{Header: got}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "admin" || pass != "Harbor12345" {
+		t.Errorf("Authorization is %q, want the basic auth of admin", got.Get("Authorization"))
+	}
+	if v := got.Get("User-Agent"); v != opts.UA {
+		t.Errorf("User-Agent is %q, want %q", v, opts.UA)
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	opts := DefaultHarborOpts()
+	opts.Url = "http://127.0.0.1/api"
+	opts.Headers = []string{"no-value"}
+	opts.AllowDefaultPassword = true
+	if _, err := NewExporter(WithHarborOpts(opts)); err == nil {
+		t.Error("the header without = is accepted")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	harbor := httptest.NewServer(echoHost)
+	defer harbor.Close()
+	p := port(t, harbor)
+
+	for _, resolve := range []string{
+		"harbor.test=127.0.0.1",
+		"harbor.test:" + p + "=127.0.0.1:" + p,
+		"HARBOR.test=127.0.0.1",
+	} {
+		t.Run(resolve, func(t *testing.T) {
+			opts := DefaultHarborOpts()
+			opts.Url = "http://harbor.test:" + p + "/api"
+			opts.Resolve = []string{resolve}
+			if host := get(t, newTestClient(t, opts), "/systeminfo"); host != "harbor.test:"+p {
+				t.Errorf("Host is %q, want harbor.test:%s", host, p)
+			}
+		})
+	}
+}
+
+func TestResolveKeepsSNI(t *testing.T) {
+	sni := make(chan string, 1)
+	harbor := httptest.NewUnstartedServer(echoHost)
+	harbor.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni <- hello.ServerName
+			return nil, nil
+		},
+	}
+	harbor.StartTLS()
+	defer harbor.Close()
+	p := port(t, harbor)
+
+	// the certificate of httptest is for example.com, so it is verified by the original host
+	opts := DefaultHarborOpts()
+	opts.Url = "https://example.com:" + p + "/api"
+	opts.Resolve = []string{"example.com=127.0.0.1"}
+	opts.TLSCAFile = writeCA(t, harbor)
+	if host := get(t, newTestClient(t, opts), "/systeminfo"); host != "example.com:"+p {
+		t.Errorf("Host is %q, want example.com:%s", host, p)
+	}
+	if name := <-sni; name != "example.com" {
+		t.Errorf("SNI is %q, want example.com", name)
+	}
+}
+
+func TestHTTP2Toggle(t *testing.T) {
+	harbor := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	harbor.EnableHTTP2 = true
+	harbor.StartTLS()
+	defer harbor.Close()
+
+	for _, c := range []struct {
+		http2 bool
+		proto string
+	}{
+		{false, "HTTP/1.1"},
+		{true, "HTTP/2.0"},
+	} {
+		opts := DefaultHarborOpts()
+		opts.Url = harbor.URL + "/api"
+		opts.TLSCAFile = writeCA(t, harbor)
+		opts.HTTP2 = c.http2
+		if proto := get(t, newTestClient(t, opts), "/systeminfo"); proto != c.proto {
+			t.Errorf("http2 %v negotiated %s, want %s", c.http2, proto, c.proto)
+		}
+	}
+}
+
+func TestPoolSettings(t *testing.T) {
+	opts := DefaultHarborOpts()
+	opts.MaxIdleConns = 7
+	opts.MaxIdleConnsPerHost = 3
+	opts.MaxConnsPerHost = 5
+	opts.IdleConnTimeout = time.Minute
+
+	newTransport, err := opts.transportFactory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newTransport(&tls.Config{})
+	if tr.MaxIdleConns != 7 || tr.MaxIdleConnsPerHost != 3 || tr.MaxConnsPerHost != 5 || tr.IdleConnTimeout != time.Minute {
+		t.Errorf("pool settings %d %d %d %s are not applied", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+	}
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SkipReasonVersion = "version"
+)
+
+var versionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Version of harbor, only the numbers are kept, e.g. v1.10.3-6b84a7e4 is 1.10.3.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses the harbor_version of /systeminfo or --override-version.
+func ParseVersion(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid harbor version: %q", s)
+	}
+
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+func mustParseVersion(s string) Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 when v is older, the same or newer than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range [...]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// VersionRange is the harbor versions a scraper supports.
+// Min is inclusive and Max is exclusive, empty means no bound.
+// Broken are the versions known to break the scraper inside the range.
+type VersionRange struct {
+	Min    string
+	Max    string
+	Broken []string
+}
+
+// Supports reports whether v is in the range, with the reason if it isn't.
+func (r VersionRange) Supports(v Version) (bool, string) {
+	if r.Min != "" && v.Compare(mustParseVersion(r.Min)) < 0 {
+		return false, fmt.Sprintf("%s is older than %s", v, r.Min)
+	}
+	if r.Max != "" && v.Compare(mustParseVersion(r.Max)) >= 0 {
+		return false, fmt.Sprintf("%s is not older than %s", v, r.Max)
+	}
+	for _, b := range r.Broken {
+		if v.Compare(mustParseVersion(b)) == 0 {
+			return false, fmt.Sprintf("%s is known broken", v)
+		}
+	}
+	return true, ""
+}
+
+func (r VersionRange) String() string {
+	s := "all"
+	switch {
+	case r.Min != "" && r.Max != "":
+		s = fmt.Sprintf("%s <= x < %s", r.Min, r.Max)
+	case r.Min != "":
+		s = fmt.Sprintf("%s <= x", r.Min)
+	case r.Max != "":
+		s = fmt.Sprintf("x < %s", r.Max)
+	}
+	if len(r.Broken) != 0 {
+		s += fmt.Sprintf(", broken %v", r.Broken)
+	}
+	return s
+}
+
+// VersionedScraper is implemented by the scrapers which only work on some harbor versions.
+type VersionedScraper interface {
+	Scraper
+	SupportedVersions() VersionRange
+}
+
+// VersionSwitcher is implemented by the scrapers which have another implementation for some harbor versions.
+type VersionSwitcher interface {
+	Scraper
+	ForVersion(v Version) Scraper
+}
+
+// versionInfo is the detected harbor version, refreshed with the permissions.
+type versionInfo struct {
+	mu      sync.RWMutex
+	checked time.Time
+	known   bool
+	raw     string
+	version Version
+}
+
+// refreshVersion detects the harbor version from /systeminfo unless it is overridden,
+// a version without numbers is treated as unknown and every scraper is run.
+func (h *HarborClient) refreshVersion(force bool) error {
+	h.version.mu.RLock()
+	fresh := !h.version.checked.IsZero() && time.Since(h.version.checked) < h.Opts.PermissionRefreshInterval
+	h.version.mu.RUnlock()
+	if fresh && !force {
+		return nil
+	}
+
+	raw := h.Opts.OverrideVersion
+	if raw == "" {
+		info, err := h.api().SystemInfo()
+		if err != nil {
+			return err
+		}
+		raw = info.HarborVersion
+	}
+
+	v, err := ParseVersion(raw)
+
+	h.version.mu.Lock()
+	h.version.checked = time.Now()
+	h.version.known = err == nil
+	h.version.raw = raw
+	h.version.version = v
+	h.version.mu.Unlock()
+
+	if err != nil {
+		h.log().Warnf("%s, all the collectors are enabled, could be set by --override-version", err)
+		return nil
+	}
+	h.log().WithField("version", v).Debug("detected the harbor version")
+	return nil
+}
+
+// harborVersion returns the detected harbor version and whether it is known.
+func (h *HarborClient) harborVersion() (Version, bool) {
+	h.version.mu.RLock()
+	defer h.version.mu.RUnlock()
+	return h.version.version, h.version.known
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	for _, c := range []struct {
+		version string
+		scraper Scraper
+		want    Scraper
+		reason  string
+	}{
+		{"v1.10.3", ScrapeLogs{}, ScrapeLogs{}, ""},
+		{"v2.1.0", ScrapeLogs{}, scrapeAuditLogs{}, ""},
+		{"v1.10.3", ScrapeProjects{}, ScrapeProjects{}, ""},
+		{"v1.8.1", ScrapeProjects{}, ScrapeProjects{}, SkipReasonVersion},
+		{"v2.1.0", ScrapeProjects{}, scrapeProjectsV2{}, ""},
+	} {
+		opts := DefaultHarborOpts()
+		opts.Url = "http://127.0.0.1/api"
+		opts.AllowDefaultPassword = true
+		opts.OverrideVersion = c.version
+		e, err := NewExporter(WithHarborOpts(opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.client.refreshVersion(true); err != nil {
+			t.Fatal(err)
+		}
+		got, reason, _ := e.resolve(c.scraper)
+		if reflect.TypeOf(got) != reflect.TypeOf(c.want) || reason != c.reason {
+			t.Errorf("%s on %s is resolved to %T skipped by %q, want %T skipped by %q",
+				c.scraper.Name(), c.version, got, reason, c.want, c.reason)
+		}
+	}
+}
+
+func TestScrapeV2(t *testing.T) {
+	answers := map[string]string{
+		"/api/v2.0/audit-logs":                    `[{"id":3}]`,
+		"/api/v2.0/projects":                      `[{"project_id":1,"name":"library"}]`,
+		"/api/v2.0/projects/1":                    `{"project_id":1,"name":"library"}`,
+		"/api/v2.0/projects/library/logs":         `[{"id":2}]`,
+		"/api/v2.0/projects/1/metadatas":          `{"public":"true"}`,
+		"/api/v2.0/projects/1/metadatas/public":   `{"public":"true"}`,
+		"/api/v2.0/projects/1/members":            `[{"id":1}]`,
+		"/api/v2.0/projects/1/members/1":          `{"id":1}`,
+		"/api/v2.0/projects/library/repositories": `[{"id":1,"name":"library/nginx"}]`,
+	}
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := answers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	defer harbor.Close()
+
+	opts := DefaultHarborOpts()
+	opts.Url = harbor.URL + "/api/v2.0"
+	client := newTestClient(t, opts)
+
+	for _, c := range []struct {
+		scraper Scraper
+		refs    []string
+	}{
+		{scrapeAuditLogs{}, []string{"/audit-logs"}},
+		{scrapeProjectsV2{}, []string{
+			"/projects",
+			"/projects/{project_id}",
+			"/projects/{project_name}/logs",
+			"/projects/{project_id}/metadatas",
+			"/projects/{project_id}/metadatas/{meta_name}",
+			"/projects/{project_id}/members",
+			"/projects/{project_id}/members/{mid}",
+			"/projects/{project_name}/repositories",
+		}},
+	} {
+		got, err := scrapeProbes(t, c.scraper, client)
+		if err != nil {
+			t.Errorf("%s: %s", c.scraper.Name(), err)
+		}
+		if len(got) != len(c.refs) {
+			t.Errorf("%s probed %v, want %v", c.scraper.Name(), got, c.refs)
+		}
+		for _, ref := range c.refs {
+			if r := got[ref]; r != (probeResult{1, 200}) {
+				t.Errorf("%s is %+v, want the success", ref, r)
+			}
+		}
+	}
+}
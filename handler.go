@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"github.com/zhangguanzhang/harbor_exporter/collector"
+)
+
+// newHandler serves the metrics of the scrapers selected by the collect[] params,
+// e.g. /metrics?collect[]=health&collect[]=statistics, all the enabled ones without params.
+func newHandler(exporter *collector.Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters := r.URL.Query()["collect[]"]
+		log.Debug("collect[] params: ", strings.Join(filters, ","))
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter.Filter(filters))
+
+		gatherers := prometheus.Gatherers{
+			prometheus.DefaultGatherer,
+			registry,
+		}
+		// Delegate http serving to Prometheus client library, which will call collector.Collect.
+		h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{
+			ErrorLog: log.StandardLogger(),
+		})
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,216 @@
+package harborclient
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) SystemInfo() (*SystemInfo, error) {
+	var v SystemInfo
+	return &v, c.Get("/systeminfo", &v)
+}
+
+// SystemVolumes needs the system admin.
+func (c *Client) SystemVolumes() (*SystemVolumes, error) {
+	var v SystemVolumes
+	return &v, c.Get("/systeminfo/volumes", &v)
+}
+
+func (c *Client) Statistics() (*Statistics, error) {
+	var v Statistics
+	return &v, c.Get("/statistics", &v)
+}
+
+func (c *Client) Health() (*Health, error) {
+	var v Health
+	return &v, c.Get("/health", &v)
+}
+
+// Configurations needs the system admin.
+func (c *Client) Configurations() (Configurations, error) {
+	var v Configurations
+	err := c.Get("/configurations", &v)
+	return v, err
+}
+
+func (c *Client) Projects(opts ListOptions) ([]Project, error) {
+	var v []Project
+	err := c.list("/projects", opts, &v)
+	return v, err
+}
+
+func (c *Client) Project(projectID int) (*Project, error) {
+	var v Project
+	return &v, c.Get("/projects/"+strconv.Itoa(projectID), &v)
+}
+
+func (c *Client) ProjectMetadatas(projectID int) (ProjectMetadata, error) {
+	var v ProjectMetadata
+	err := c.Get(fmt.Sprintf("/projects/%d/metadatas", projectID), &v)
+	return v, err
+}
+
+func (c *Client) ProjectMetadata(projectID int, name string) (ProjectMetadata, error) {
+	var v ProjectMetadata
+	err := c.Get(fmt.Sprintf("/projects/%d/metadatas/%s", projectID, url.PathEscape(name)), &v)
+	return v, err
+}
+
+func (c *Client) ProjectMembers(projectID int) ([]ProjectMember, error) {
+	var v []ProjectMember
+	err := c.Get(fmt.Sprintf("/projects/%d/members", projectID), &v)
+	return v, err
+}
+
+func (c *Client) ProjectMember(projectID, memberID int) (*ProjectMember, error) {
+	var v ProjectMember
+	return &v, c.Get(fmt.Sprintf("/projects/%d/members/%d", projectID, memberID), &v)
+}
+
+// ProjectLogs is v1 only.
+func (c *Client) ProjectLogs(projectID int, opts ListOptions) ([]AuditLog, error) {
+	var v []AuditLog
+	err := c.list(fmt.Sprintf("/projects/%d/logs", projectID), opts, &v)
+	return v, err
+}
+
+// ProjectAuditLogs is v2 only, see ProjectLogs for v1.
+func (c *Client) ProjectAuditLogs(projectName string, opts ListOptions) ([]AuditLog, error) {
+	var v []AuditLog
+	err := c.list("/projects/"+url.PathEscape(projectName)+"/logs", opts, &v)
+	return v, err
+}
+
+// Repositories of the project is v1 only.
+func (c *Client) Repositories(projectID int, opts ListOptions) ([]Repository, error) {
+	if opts.Params == nil {
+		opts.Params = map[string]string{}
+	}
+	opts.Params["project_id"] = strconv.Itoa(projectID)
+	var v []Repository
+	err := c.list("/repositories", opts, &v)
+	return v, err
+}
+
+// TopRepositories is v1 only.
+func (c *Client) TopRepositories(count int) ([]Repository, error) {
+	var v []Repository
+	err := c.Get("/repositories/top?count="+strconv.Itoa(count), &v)
+	return v, err
+}
+
+// ProjectRepositories is v2 only.
+func (c *Client) ProjectRepositories(projectName string, opts ListOptions) ([]Repository, error) {
+	var v []Repository
+	err := c.list("/projects/"+url.PathEscape(projectName)+"/repositories", opts, &v)
+	return v, err
+}
+
+// Artifacts is v2 only, the repository name doesn't contain the project name.
+func (c *Client) Artifacts(projectName, repositoryName string, opts ListOptions) ([]Artifact, error) {
+	var v []Artifact
+	// the slashes in the repository name must be escaped twice
+	repo := url.PathEscape(url.PathEscape(repositoryName))
+	err := c.list("/projects/"+url.PathEscape(projectName)+"/repositories/"+repo+"/artifacts", opts, &v)
+	return v, err
+}
+
+// ScanAllMetrics is the progress of the latest manual scan all.
+func (c *Client) ScanAllMetrics() (*ScanAllMetrics, error) {
+	var v ScanAllMetrics
+	return &v, c.Get("/scans/all/metrics", &v)
+}
+
+// ScheduleScanAllMetrics is the progress of the latest scheduled scan all.
+func (c *Client) ScheduleScanAllMetrics() (*ScanAllMetrics, error) {
+	var v ScanAllMetrics
+	return &v, c.Get("/scans/schedule/metrics", &v)
+}
+
+// Users needs the system admin.
+func (c *Client) Users(opts ListOptions) ([]User, error) {
+	var v []User
+	err := c.list("/users", opts, &v)
+	return v, err
+}
+
+func (c *Client) User(userID int) (*User, error) {
+	var v User
+	return &v, c.Get("/users/"+strconv.Itoa(userID), &v)
+}
+
+func (c *Client) CurrentUser() (*User, error) {
+	var v User
+	return &v, c.Get("/users/current", &v)
+}
+
+// CurrentUserPermissions without the scope is empty on some versions.
+func (c *Client) CurrentUserPermissions() ([]Permission, error) {
+	var v []Permission
+	err := c.Get("/users/current/permissions", &v)
+	return v, err
+}
+
+// Quotas is since v1.9.
+func (c *Client) Quotas(opts ListOptions) ([]Quota, error) {
+	var v []Quota
+	err := c.list("/quotas", opts, &v)
+	return v, err
+}
+
+func (c *Client) ReplicationPolicies(opts ListOptions) ([]ReplicationPolicy, error) {
+	var v []ReplicationPolicy
+	err := c.list("/replication/policies", opts, &v)
+	return v, err
+}
+
+func (c *Client) ReplicationExecutions(policyID int, opts ListOptions) ([]ReplicationExecution, error) {
+	if opts.Params == nil {
+		opts.Params = map[string]string{}
+	}
+	opts.Params["policy_id"] = strconv.Itoa(policyID)
+	var v []ReplicationExecution
+	err := c.list("/replication/executions", opts, &v)
+	return v, err
+}
+
+func (c *Client) ReplicationAdapters() ([]string, error) {
+	var v []string
+	err := c.Get("/replication/adapters", &v)
+	return v, err
+}
+
+func (c *Client) Registries() ([]Registry, error) {
+	var v []Registry
+	err := c.Get("/registries", &v)
+	return v, err
+}
+
+// GCHistory of v1 doesn't support the pagination and returns all the gc jobs.
+func (c *Client) GCHistory(opts ListOptions) ([]GCHistory, error) {
+	var v []GCHistory
+	err := c.list("/system/gc", opts, &v)
+	return v, err
+}
+
+// Labels needs the scope param, g for the global labels and p with the project_id.
+func (c *Client) Labels(opts ListOptions) ([]Label, error) {
+	var v []Label
+	err := c.list("/labels", opts, &v)
+	return v, err
+}
+
+// Logs is v1 only, see AuditLogs for v2.
+func (c *Client) Logs(opts ListOptions) ([]AuditLog, error) {
+	var v []AuditLog
+	err := c.list("/logs", opts, &v)
+	return v, err
+}
+
+// AuditLogs is v2 only.
+func (c *Client) AuditLogs(opts ListOptions) ([]AuditLog, error) {
+	var v []AuditLog
+	err := c.list("/audit-logs", opts, &v)
+	return v, err
+}
@@ -0,0 +1,121 @@
+// Package harborclient is a typed client of the harbor v1 (/api) and v2 (/api/v2.0) APIs.
+//
+// The Client only decodes the responses, the requests are sent by a Requester,
+// e.g. the HTTPRequester or the instrumented client of the exporter.
+package harborclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Requester gets the response body of an endpoint relative to the API base url, e.g. /projects?page=1.
+type Requester interface {
+	Request(endpoint string) ([]byte, error)
+}
+
+// StatusError is returned when harbor answers with a non-200 status code.
+type StatusError struct {
+	Endpoint string
+	Code     int
+	Status   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error handling request for %s http-statuscode: %s", e.Endpoint, e.Status)
+}
+
+// HTTPRequester is a plain Requester with the basic auth.
+type HTTPRequester struct {
+	// BaseURL is the API address, e.g. https://harbor.dev/api or https://harbor.dev/api/v2.0
+	BaseURL   string
+	Username  string
+	Password  string
+	UserAgent string
+	Client    *http.Client // http.DefaultClient if nil
+}
+
+func (r *HTTPRequester) Request(endpoint string) ([]byte, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(r.BaseURL, "/")+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Endpoint: endpoint, Code: resp.StatusCode, Status: resp.Status}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Client is the typed harbor API client, the methods are only valid for the API version
+// noted on them, the others work on both v1 and v2.
+type Client struct {
+	r Requester
+}
+
+func New(r Requester) *Client {
+	return &Client{r: r}
+}
+
+// Get requests the endpoint and decodes the JSON response into v.
+func (c *Client) Get(endpoint string, v interface{}) error {
+	body, err := c.r.Request(endpoint)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode the response of %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// ListOptions are the pagination and the query parameters of the list APIs,
+// the zero values are not sent.
+type ListOptions struct {
+	Page     int
+	PageSize int
+	Params   map[string]string
+}
+
+func (o ListOptions) encode() string {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	for k, v := range o.Params {
+		q.Set(k, v)
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+func (c *Client) list(endpoint string, opts ListOptions, v interface{}) error {
+	return c.Get(endpoint+opts.encode(), v)
+}
@@ -0,0 +1,259 @@
+package harborclient
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SystemInfo of /systeminfo, the anonymous users only get part of the fields.
+type SystemInfo struct {
+	HarborVersion               string `json:"harbor_version"`
+	RegistryURL                 string `json:"registry_url"`
+	ExternalURL                 string `json:"external_url"`
+	AuthMode                    string `json:"auth_mode"`
+	ProjectCreationRestriction  string `json:"project_creation_restriction"`
+	SelfRegistration            bool   `json:"self_registration"`
+	HasCaRoot                   bool   `json:"has_ca_root"`
+	ReadOnly                    *bool  `json:"read_only"` // nil when it is not answered, e.g. to the anonymous users
+	WithNotary                  bool   `json:"with_notary"`
+	WithChartmuseum             bool   `json:"with_chartmuseum"`
+	RegistryStorageProviderName string `json:"registry_storage_provider_name"`
+	NotificationEnable          bool   `json:"notification_enable"`
+
+	// v1 only
+	WithClair       bool   `json:"with_clair"`
+	WithAdmiral     bool   `json:"with_admiral"`
+	AdmiralEndpoint string `json:"admiral_endpoint"`
+	ClairVulnStatus *struct {
+		OverallLastUpdate int64 `json:"overall_last_update"`
+	} `json:"clair_vulnerability_status,omitempty"`
+
+	// v2 only
+	PrimaryAuthMode  bool   `json:"primary_auth_mode"`
+	OIDCProviderName string `json:"oidc_provider_name"`
+	CurrentTime      string `json:"current_time"`
+}
+
+// SystemVolumes of /systeminfo/volumes, in bytes.
+type SystemVolumes struct {
+	Storage struct {
+		Total float64 `json:"total"`
+		Free  float64 `json:"free"`
+	} `json:"storage"`
+}
+
+// Statistics of /statistics, the fields are nil when harbor doesn't answer them,
+// e.g. the totals are only answered to the system admin, the storage consumption since v2.
+type Statistics struct {
+	PrivateProjectCount     *float64 `json:"private_project_count"`
+	PrivateRepoCount        *float64 `json:"private_repo_count"`
+	PublicProjectCount      *float64 `json:"public_project_count"`
+	PublicRepoCount         *float64 `json:"public_repo_count"`
+	TotalProjectCount       *float64 `json:"total_project_count"`
+	TotalRepoCount          *float64 `json:"total_repo_count"`
+	TotalStorageConsumption *float64 `json:"total_storage_consumption"` // bytes
+}
+
+// Health of /health, since v1.8.
+type Health struct {
+	Status     string            `json:"status"`
+	Components []ComponentHealth `json:"components"`
+}
+
+type ComponentHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Project struct {
+	ProjectID         int               `json:"project_id"`
+	Name              string            `json:"name"`
+	OwnerID           int               `json:"owner_id"`
+	OwnerName         string            `json:"owner_name"`
+	RepoCount         int               `json:"repo_count"`
+	ChartCount        int               `json:"chart_count"`
+	Metadata          map[string]string `json:"metadata"`
+	CurrentUserRoleID int               `json:"current_user_role_id"`
+	CreationTime      time.Time         `json:"creation_time"`
+	UpdateTime        time.Time         `json:"update_time"`
+	Deleted           json.RawMessage   `json:"deleted"` // int in v1, bool in v2
+}
+
+// ProjectMetadata of /projects/{project_id}/metadatas, e.g. public, auto_scan.
+type ProjectMetadata map[string]string
+
+type ProjectMember struct {
+	ID         int    `json:"id"`
+	ProjectID  int    `json:"project_id"`
+	EntityName string `json:"entity_name"`
+	EntityType string `json:"entity_type"`
+	EntityID   int    `json:"entity_id"`
+	RoleID     int    `json:"role_id"`
+	RoleName   string `json:"role_name"`
+}
+
+// Repository of the v1 /repositories and the v2 /projects/{project_name}/repositories.
+type Repository struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	ProjectID    int       `json:"project_id"`
+	Description  string    `json:"description"`
+	PullCount    float64   `json:"pull_count"`
+	CreationTime time.Time `json:"creation_time"`
+	UpdateTime   time.Time `json:"update_time"`
+
+	// v1 only
+	StarCount float64 `json:"star_count"`
+	TagsCount float64 `json:"tags_count"`
+
+	// v2 only
+	ArtifactCount float64 `json:"artifact_count"`
+}
+
+// Artifact of v2, with_scan_overview adds the ScanOverview keyed by the report mime type.
+type Artifact struct {
+	ID           int                     `json:"id"`
+	Type         string                  `json:"type"`
+	Digest       string                  `json:"digest"`
+	Size         int64                   `json:"size"`
+	ProjectID    int                     `json:"project_id"`
+	RepositoryID int                     `json:"repository_id"`
+	PushTime     time.Time               `json:"push_time"`
+	PullTime     time.Time               `json:"pull_time"`
+	Tags         []Tag                   `json:"tags"`
+	ScanOverview map[string]ScanOverview `json:"scan_overview,omitempty"`
+}
+
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	PushTime  time.Time `json:"push_time"`
+	PullTime  time.Time `json:"pull_time"`
+	Immutable bool      `json:"immutable"`
+}
+
+type ScanOverview struct {
+	ReportID   string `json:"report_id"`
+	ScanStatus string `json:"scan_status"`
+	Severity   string `json:"severity"`
+	Summary    *struct {
+		Total   int            `json:"total"`
+		Fixable int            `json:"fixable"`
+		Summary map[string]int `json:"summary"`
+	} `json:"summary,omitempty"`
+}
+
+// ScanAllMetrics of /scans/all/metrics and /scans/schedule/metrics.
+type ScanAllMetrics struct {
+	Total     int            `json:"total"`
+	Completed int            `json:"completed"`
+	Metrics   map[string]int `json:"metrics"` // count by the status
+	Requester string         `json:"requester"`
+	Ongoing   bool           `json:"ongoing"`
+	Trigger   string         `json:"trigger"`
+}
+
+type User struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Realname string `json:"realname"`
+	// v2
+	SysAdminFlag    bool `json:"sysadmin_flag"`
+	AdminRoleInAuth bool `json:"admin_role_in_auth"`
+	// v1 before the sysadmin_flag
+	HasAdminRole bool `json:"has_admin_role"`
+}
+
+// IsSysAdmin reports whether the user has the system admin role on any version.
+func (u User) IsSysAdmin() bool {
+	return u.SysAdminFlag || u.HasAdminRole || u.AdminRoleInAuth
+}
+
+// Permission is a resource and action pair of the harbor RBAC, e.g. {"/project/1/repository", "pull"}.
+type Permission struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// Quota of /quotas, since v1.9, hard and used are keyed by the resource, e.g. storage.
+type Quota struct {
+	ID  int `json:"id"`
+	Ref struct {
+		ID        int    `json:"id"`
+		Name      string `json:"name"`
+		OwnerName string `json:"owner_name"`
+	} `json:"ref"`
+	Hard map[string]int64 `json:"hard"`
+	Used map[string]int64 `json:"used"`
+}
+
+type ReplicationPolicy struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+type ReplicationExecution struct {
+	ID         int       `json:"id"`
+	PolicyID   int       `json:"policy_id"`
+	Status     string    `json:"status"`
+	Trigger    string    `json:"trigger"`
+	Total      int       `json:"total"`
+	Failed     int       `json:"failed"`
+	Succeed    int       `json:"succeed"`
+	InProgress int       `json:"in_progress"`
+	Stopped    int       `json:"stopped"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+}
+
+// Registry is the replication endpoint of /registries.
+type Registry struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Insecure bool   `json:"insecure"`
+}
+
+// GCHistory of /system/gc, the v1 API doesn't support the pagination.
+type GCHistory struct {
+	ID           int       `json:"id"`
+	JobName      string    `json:"job_name"`
+	JobKind      string    `json:"job_kind"`
+	JobStatus    string    `json:"job_status"`
+	Deleted      bool      `json:"deleted"`
+	CreationTime time.Time `json:"creation_time"`
+	UpdateTime   time.Time `json:"update_time"`
+}
+
+type Label struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	ProjectID int    `json:"project_id"`
+}
+
+// AuditLog of the v1 /logs and /projects/{project_id}/logs, or the v2 /audit-logs.
+type AuditLog struct {
+	ID           int       `json:"id"`
+	LogID        int       `json:"log_id"` // v1
+	ProjectID    int       `json:"project_id"`
+	Username     string    `json:"username"`
+	Resource     string    `json:"resource"`
+	ResourceType string    `json:"resource_type"`
+	Operation    string    `json:"operation"`
+	OpTime       time.Time `json:"op_time"`
+}
+
+// ConfigItem is a setting of /configurations, Value is a string, a number or a bool.
+type ConfigItem struct {
+	Value    interface{} `json:"value"`
+	Editable bool        `json:"editable"`
+}
+
+type Configurations map[string]ConfigItem
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/coreos/go-systemd/daemon"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"github.com/zhangguanzhang/harbor_exporter/collector"
+	"github.com/zhangguanzhang/harbor_exporter/otlp"
+	"github.com/zhangguanzhang/harbor_exporter/pusher"
+	"github.com/zhangguanzhang/harbor_exporter/web"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"syscall"
-	"github.com/coreos/go-systemd/daemon"
 )
 
-func LogInit(level , file string) error {
+func LogInit(level, file string) error {
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02 15:04:05",
@@ -39,26 +45,40 @@ func LogInit(level , file string) error {
 }
 
 func main() {
+	// `harbor_exporter check [flags]` validates the config and exits
+	checkMode := len(os.Args) > 1 && os.Args[1] == "check"
+	if checkMode {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 
 	listenAddress := flag.String("web.listen-address", ":9107", "Address to listen on for web interface and telemetry.")
 	metricsPath := flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+	webConfig := flag.String("web.config.file", "", "Path to the configuration file that can enable TLS or authentication, the format of the prometheus exporter-toolkit. See: https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md")
 	logLevel := flag.String("log-level", "info", "The logging level:[debug, info, warn, error, fatal]")
 	logFile := flag.String("log-output", "", "the file which log to, default stdout")
 	versionP := flag.Bool("version", false, "print version info")
-	flag.StringVar(&collector.HarborVersion, "override-version", "", "override the harbor version")
+	printMetrics := flag.String("print-metrics", "", "print the catalog of every metric with its labels, collectors and supported harbor versions, then exit: [markdown, json]")
+	flag.Lookup("print-metrics").NoOptDefVal = "markdown"
+	listCollectors := flag.Bool("list-collectors", false, "print the collectors with their default state, cost, required permissions and supported harbor versions, then exit")
+	checkFormat := flag.String("check.format", "text", "The report format of the check subcommand: [text, json]")
+	once := flag.Bool("once", false, "Scrape harbor once, write the metrics to --output and exit, non-zero exit code if the scrape has errors.")
+	output := flag.String("output", "", "The file which --once writes the metrics to, e.g. a .prom file of the node_exporter textfile collector, default stdout. Implies --once.")
 
-	opts := &collector.HarborOpts{}
+	opts := collector.DefaultHarborOpts()
 	opts.AddFlag()
 
+	pushOpts := pusher.DefaultOpts()
+	pushOpts.AddFlag()
+
+	otlpOpts := otlp.DefaultOpts()
+	otlpOpts.AddFlag()
+
 	// Generate ON/OFF flags for all scrapers.
-	scraperFlags := map[collector.Scraper]*bool{}
-	for scraper, enabledByDefault := range collector.Scrapers {
-		defaultOn := false
-		if enabledByDefault {
-			defaultOn = true
-		}
-		f := flag.Bool("collect."+scraper.Name(), defaultOn, scraper.Help())
-		scraperFlags[scraper] = f
+	registry := collector.DefaultRegistry()
+	scrapers := registry.Scrapers()
+	scraperFlags := make([]*bool, len(scrapers))
+	for i, info := range scrapers {
+		scraperFlags[i] = flag.Bool("collect."+info.Name(), info.EnabledByDefault, info.Help())
 	}
 
 	flag.Parse()
@@ -68,17 +88,42 @@ func main() {
 		return
 	}
 
+	if *printMetrics != "" {
+		if err := registry.WriteMetricCatalog(os.Stdout, *printMetrics); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *listCollectors {
+		if err := registry.WriteCatalog(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := LogInit(*logLevel, *logFile); err != nil {
 		log.Fatal(errors.Wrap(err, "set log level error"))
 	}
+	log.AddHook(opts.Redactor())
 
+	if err := web.Validate(*webConfig); err != nil {
+		log.Fatal(errors.Wrap(err, "invalid web config file"))
+	}
+
+	if user := os.Getenv("HARBOR_USERNAME"); user != "" {
+		opts.Username = user
+	}
+	if pass := os.Getenv("HARBOR_PASSWORD"); pass != "" {
+		opts.SetPassword(pass)
+	}
 
 	// Register only scrapers enabled by flag.
 	enabledScrapers := []collector.Scraper{}
-	for scraper, enabled := range scraperFlags {
-		if *enabled {
-			log.Info("Scraper enabled ", scraper.Name())
-			enabledScrapers = append(enabledScrapers, scraper)
+	for i, info := range scrapers {
+		if *scraperFlags[i] {
+			log.Info("Scraper enabled ", info.Name())
+			enabledScrapers = append(enabledScrapers, info.Scraper)
 		}
 	}
 
@@ -87,7 +132,46 @@ func main() {
 		log.Fatal(err)
 	}
 
-	prometheus.MustRegister(exporter)
+	if checkMode {
+		os.Exit(runCheck(exporter, *checkFormat))
+	}
+
+	if *once || *output != "" {
+		os.Exit(runOnce(exporter, *output))
+	}
+
+	if err := exporter.Discover(); err != nil {
+		log.Warn(errors.Wrap(err, "discover the harbor version and permissions"))
+	}
+
+	if otlpOpts.TracesEnabled() {
+		tracer, err := otlp.NewTracer(otlpOpts, exporter.Instance())
+		if err != nil {
+			log.Fatal(err)
+		}
+		exporter.SetTracer(tracer)
+	}
+
+	if pushOpts.Enabled() || otlpOpts.MetricsEnabled() {
+		pushMetrics := pusher.NewMetrics()
+		prometheus.MustRegister(pushMetrics)
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(exporter, pushMetrics)
+		p, err := pusher.New(pushOpts, pushMetrics, reg, exporter.Instance())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if otlpOpts.MetricsEnabled() {
+			push, err := otlp.NewMetricsPush(otlpOpts, exporter.Instance())
+			if err != nil {
+				log.Fatal(err)
+			}
+			p.AddMode(pusher.ModeOTLP, push)
+		}
+		log.Infof("Pushing metrics every %s", pushOpts.Interval)
+		go p.Run(nil)
+	}
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
@@ -103,12 +187,7 @@ func main() {
 
 	http.Handle(*metricsPath, promhttp.InstrumentMetricHandler(
 		prometheus.DefaultRegisterer,
-		promhttp.HandlerFor(
-			prometheus.DefaultGatherer,
-			promhttp.HandlerOpts{
-				ErrorLog: log.StandardLogger(),
-			},
-		),
+		newHandler(exporter),
 	),
 	)
 
@@ -123,7 +202,8 @@ func main() {
 
 	daemon.SdNotify(false, daemon.SdNotifyReady)
 
-	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
+	server := &http.Server{Addr: *listenAddress, Handler: http.DefaultServeMux}
+	if err := web.ListenAndServe(server, *webConfig); err != nil {
 		log.Fatal(err)
 	}
 
@@ -148,6 +228,86 @@ Platform: %s/%s
 `, collector.Name(), Version, gitCommit, gitTreeState, buildDate, runtime.Version(), runtime.Compiler, runtime.GOOS, runtime.GOARCH)
 }
 
+// runCheck prints the check report and returns the exit code.
+func runCheck(exporter *collector.Exporter, format string) int {
+	report := exporter.Check()
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Error(err)
+			return 2
+		}
+	case "text":
+		report.WriteText(os.Stdout)
+	default:
+		log.Errorf("unknown check format %q", format)
+		return 2
+	}
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// runOnce writes the metrics of a single scrape and returns the exit code,
+// the file is replaced atomically so the textfile collector never reads a partial one.
+func runOnce(exporter *collector.Exporter, output string) int {
+	if err := exporter.Discover(); err != nil {
+		log.Warn(errors.Wrap(err, "discover the harbor version and permissions"))
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exporter)
+
+	var failed bool
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := reg.Gather()
+		failed = lastScrapeFailed(mfs)
+		return mfs, err
+	})
+
+	var err error
+	if output == "" {
+		var mfs []*dto.MetricFamily
+		if mfs, err = gatherer.Gather(); err == nil {
+			for _, mf := range mfs {
+				if _, err = expfmt.MetricFamilyToText(os.Stdout, mf); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		err = prometheus.WriteToTextfile(output, gatherer)
+	}
+	if err != nil {
+		log.Error(errors.Wrap(err, "write metrics"))
+		return 2
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func lastScrapeFailed(mfs []*dto.MetricFamily) bool {
+	for _, mf := range mfs {
+		if mf.GetName() != "harbor_exporter_last_scrape_error" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func setupSigusr1Trap() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGUSR1)
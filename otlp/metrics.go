@@ -0,0 +1,197 @@
+package otlp
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Gauge       *gauge     `json:"gauge,omitempty"`
+	Sum         *sum       `json:"sum,omitempty"`
+	Summary     *summary   `json:"summary,omitempty"`
+	Histogram   *histogram `json:"histogram,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+// aggregationTemporalityCumulative of the prometheus counters and histograms
+const aggregationTemporalityCumulative = 2
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type summaryDataPoint struct {
+	Attributes     []keyValue      `json:"attributes,omitempty"`
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	Count          string          `json:"count"`
+	Sum            *float64        `json:"sum,omitempty"`
+	QuantileValues []quantileValue `json:"quantileValues,omitempty"`
+}
+
+type quantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type histogramDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	Count             string     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	BucketCounts      []string   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+}
+
+// NewMetricsPush returns the push function of the pusher which exports
+// the metrics gathered by the pusher to /v1/metrics of the OTLP endpoint.
+func NewMetricsPush(opts *Opts, instance string) (func(mfs []*dto.MetricFamily) error, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := unixNano(time.Now())
+	return func(mfs []*dto.MetricFamily) error {
+		return c.post("/v1/metrics", convertMetrics(mfs, instance, start, unixNano(time.Now())))
+	}, nil
+}
+
+// convertMetrics maps the prometheus metric families to the OTLP metrics,
+// counters are cumulative sums started at start.
+func convertMetrics(mfs []*dto.MetricFamily, instance, start, now string) metricsRequest {
+	metrics := make([]metric, 0, len(mfs))
+	for _, mf := range mfs {
+		m := metric{Name: mf.GetName(), Description: mf.GetHelp()}
+		for _, pm := range mf.GetMetric() {
+			attrs := make([]keyValue, 0, len(pm.GetLabel()))
+			for _, lp := range pm.GetLabel() {
+				attrs = append(attrs, attribute(lp.GetName(), lp.GetValue()))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				if m.Sum == nil {
+					m.Sum = &sum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+				}
+				if !finite(pm.GetCounter().GetValue()) {
+					continue
+				}
+				m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+					Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: now, AsDouble: pm.GetCounter().GetValue(),
+				})
+			case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+				if m.Gauge == nil {
+					m.Gauge = &gauge{}
+				}
+				v := pm.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					v = pm.GetUntyped().GetValue()
+				}
+				if !finite(v) {
+					continue
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+					Attributes: attrs, TimeUnixNano: now, AsDouble: v,
+				})
+			case dto.MetricType_SUMMARY:
+				if m.Summary == nil {
+					m.Summary = &summary{}
+				}
+				s := pm.GetSummary()
+				dp := summaryDataPoint{
+					Attributes: attrs, TimeUnixNano: now,
+					Count: strconv.FormatUint(s.GetSampleCount(), 10), Sum: finiteSum(s.GetSampleSum()),
+				}
+				for _, q := range s.GetQuantile() {
+					if !finite(q.GetValue()) {
+						continue
+					}
+					dp.QuantileValues = append(dp.QuantileValues, quantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+			case dto.MetricType_HISTOGRAM:
+				if m.Histogram == nil {
+					m.Histogram = &histogram{AggregationTemporality: aggregationTemporalityCumulative}
+				}
+				h := pm.GetHistogram()
+				dp := histogramDataPoint{
+					Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: now,
+					Count: strconv.FormatUint(h.GetSampleCount(), 10), Sum: finiteSum(h.GetSampleSum()),
+				}
+				// prometheus buckets are cumulative, OTLP ones are not and have the +Inf bucket
+				var last uint64
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue
+					}
+					dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+					dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-last, 10))
+					last = b.GetCumulativeCount()
+				}
+				dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(h.GetSampleCount()-last, 10))
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, dp)
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     newResource(instance),
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+	}}}
+}
+
+// finite reports whether v could be encoded in JSON, the NaN and Inf points are dropped.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// finiteSum is the sum of a summary or a histogram, nil for the NaN or Inf sum which is left out,
+// e.g. the sum of a summary without observations.
+func finiteSum(v float64) *float64 {
+	if !finite(v) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,166 @@
+// Package otlp exports the metrics and the scrape traces to an OpenTelemetry collector
+// by OTLP/HTTP with the JSON encoding, so no OpenTelemetry SDK is needed.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const scopeName = "harbor_exporter"
+
+// Opts of the OTLP export, nothing is exported if the Endpoint is empty.
+type Opts struct {
+	Endpoint string
+	Metrics  bool
+	Traces   bool
+	Headers  []string
+	Timeout  time.Duration
+}
+
+// DefaultOpts returns the opts with the defaults of the flags, set the Endpoint to export.
+func DefaultOpts() *Opts {
+	return &Opts{
+		Metrics: true,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// AddFlag adds the flags to the global flag set.
+func (o *Opts) AddFlag() {
+	o.AddFlags(flag.CommandLine)
+}
+
+// AddFlags adds the flags to fs, the defaults are the ones of DefaultOpts.
+func (o *Opts) AddFlags(fs *flag.FlagSet) {
+	d := DefaultOpts()
+	fs.StringVar(&o.Endpoint, "otlp.endpoint", d.Endpoint, "OTLP/HTTP endpoint of the OpenTelemetry collector, e.g. http://otel-collector:4318")
+	fs.BoolVar(&o.Metrics, "otlp.metrics", d.Metrics, "Export the metrics to /v1/metrics of the OTLP endpoint every --push.interval.")
+	fs.BoolVar(&o.Traces, "otlp.traces", d.Traces, "Export a trace per scrape to /v1/traces of the OTLP endpoint.")
+	fs.StringArrayVar(&o.Headers, "otlp.header", d.Headers, "Extra header of the OTLP requests as key=value, could be repeated.")
+	fs.DurationVar(&o.Timeout, "otlp.timeout", d.Timeout, "Timeout on exporting to the OTLP endpoint.")
+}
+
+func (o *Opts) MetricsEnabled() bool {
+	return o.Endpoint != "" && o.Metrics
+}
+
+func (o *Opts) TracesEnabled() bool {
+	return o.Endpoint != "" && o.Traces
+}
+
+// client posts the OTLP JSON payloads.
+type client struct {
+	endpoint string
+	headers  http.Header
+	http     *http.Client
+}
+
+func newClient(opts *Opts) (*client, error) {
+	headers := http.Header{}
+	for _, h := range opts.Headers {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid otlp header %q, must be key=value", h)
+		}
+		headers.Add(kv[0], kv[1])
+	}
+	return &client{
+		endpoint: strings.TrimSuffix(opts.Endpoint, "/"),
+		headers:  headers,
+		http:     &http.Client{Timeout: opts.Timeout},
+	}, nil
+}
+
+func (c *client) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "harbor_exporter")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp export %s http-statuscode: %s %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// the OTLP JSON types, see opentelemetry-proto, 64 bit integers are strings.
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func attribute(key string, value interface{}) keyValue {
+	var v anyValue
+	switch val := value.(type) {
+	case string:
+		v.StringValue = &val
+	case int:
+		s := strconv.Itoa(val)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &val
+	case bool:
+		v.BoolValue = &val
+	default:
+		s := fmt.Sprint(val)
+		v.StringValue = &s
+	}
+	return keyValue{Key: key, Value: v}
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+func newResource(instance string) resource {
+	return resource{Attributes: []keyValue{
+		attribute("service.name", "harbor_exporter"),
+		attribute("service.instance.id", instance),
+	}}
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,283 @@
+package otlp
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zhangguanzhang/harbor_exporter/collector"
+)
+
+// receiver is a stand-in of the OTLP/HTTP receiver of an OpenTelemetry collector,
+// it decodes the JSON payloads and passes them on.
+type receiver struct {
+	*httptest.Server
+	traces  chan tracesRequest
+	metrics chan metricsRequest
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{traces: make(chan tracesRequest, 10), metrics: make(chan metricsRequest, 10)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || req.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "want a JSON POST", http.StatusBadRequest)
+			return
+		}
+		if req.Header.Get("X-Tenant") != "test" {
+			http.Error(w, "missing the header", http.StatusUnauthorized)
+			return
+		}
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		switch req.URL.Path {
+		case "/v1/traces":
+			var v tracesRequest
+			if err := dec.Decode(&v); err != nil {
+				t.Errorf("decode the traces: %s", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.traces <- v
+		case "/v1/metrics":
+			var v metricsRequest
+			if err := dec.Decode(&v); err != nil {
+				t.Errorf("decode the metrics: %s", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.metrics <- v
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	return r
+}
+
+func (r *receiver) opts() *Opts {
+	return &Opts{Endpoint: r.URL + "/", Metrics: true, Traces: true, Headers: []string{"X-Tenant=test"}, Timeout: 5 * time.Second}
+}
+
+// newHarbor is a stand-in of harbor which answers the ping, the credential check and /statistics.
+func newHarbor() *httptest.Server {
+	answers := map[string]string{
+		"/api/systeminfo":                `{"harbor_version":"v1.10.3","registry_url":"harbor.dev"}`,
+		"/api/users/current":             `{"user_id":1,"has_admin_role":true}`,
+		"/api/users/current/permissions": `[]`,
+		"/api/statistics":                `{"total_project_count":3,"total_repo_count":7}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := answers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); r.URL.Path != "/api/systeminfo" && (user != "admin" || pass != "Harbor12345") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+}
+
+func attrs(kvs []keyValue) map[string]anyValue {
+	m := make(map[string]anyValue, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func stringValue(v anyValue) string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.IntValue != nil:
+		return *v.IntValue
+	}
+	return ""
+}
+
+func TestTraces(t *testing.T) {
+	recv := newReceiver(t)
+	defer recv.Close()
+	harbor := newHarbor()
+	defer harbor.Close()
+
+	tracer, err := NewTracer(recv.opts(), "harbor.dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter, err := collector.NewExporter(
+		collector.WithURL(harbor.URL+"/api"),
+		collector.WithBasicAuth("admin", "Harbor12345"),
+		collector.WithScrapers(collector.ScrapeStatistics{}),
+		collector.WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(exporter)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+
+	var req tracesRequest
+	select {
+	case req = <-recv.traces:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trace is exported")
+	}
+
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("want a resource and a scope, got %+v", req)
+	}
+	if v := stringValue(attrs(req.ResourceSpans[0].Resource.Attributes)["service.instance.id"]); v != "harbor.dev" {
+		t.Errorf("service.instance.id is %q, want harbor.dev", v)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+
+	byName := make(map[string][]spanData)
+	for _, s := range spans {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	if len(byName["scrape"]) != 1 || len(byName["scrape statistics"]) != 1 || len(byName["GET /statistics"]) != 1 {
+		t.Fatalf("want the scrape, the collector and the request spans, got %v", byName)
+	}
+	root, col, httpSpan := byName["scrape"][0], byName["scrape statistics"][0], byName["GET /statistics"][0]
+
+	for _, s := range spans {
+		if s.TraceID != root.TraceID {
+			t.Errorf("span %s is in trace %s, want %s", s.Name, s.TraceID, root.TraceID)
+		}
+		if s.Name != "scrape" && s.ParentSpanID == "" {
+			t.Errorf("span %s has no parent", s.Name)
+		}
+	}
+	if root.ParentSpanID != "" {
+		t.Errorf("the scrape span has the parent %s", root.ParentSpanID)
+	}
+	if col.ParentSpanID != root.SpanID {
+		t.Errorf("the collector span is the child of %s, want the scrape span %s", col.ParentSpanID, root.SpanID)
+	}
+	if httpSpan.ParentSpanID != col.SpanID {
+		t.Errorf("the request span is the child of %s, want the collector span %s", httpSpan.ParentSpanID, col.SpanID)
+	}
+
+	if v := stringValue(attrs(col.Attributes)["collector"]); v != "statistics" {
+		t.Errorf("collector attribute is %q, want statistics", v)
+	}
+	a := attrs(httpSpan.Attributes)
+	for key, want := range map[string]string{
+		"http.method":      "GET",
+		"http.url":         harbor.URL + "/api/statistics",
+		"http.status_code": "200",
+	} {
+		if v := stringValue(a[key]); v != want {
+			t.Errorf("%s is %q, want %q", key, v, want)
+		}
+	}
+	if httpSpan.Kind != spanKindClient || col.Kind != spanKindInternal {
+		t.Errorf("kinds are %d and %d, want client and internal", httpSpan.Kind, col.Kind)
+	}
+	if httpSpan.Status.Code != statusCodeOk || col.Status.Code != statusCodeOk {
+		t.Errorf("status are %+v and %+v, want ok", httpSpan.Status, col.Status)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	recv := newReceiver(t)
+	defer recv.Close()
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "requests"}, []string{"code"})
+	counter.WithLabelValues("200").Add(3)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_up", Help: "up"})
+	gauge.Set(1)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Help: "duration", Buckets: []float64{0.1, 1}})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	// a summary without observations has the NaN quantiles, and the NaN sum here
+	summary := prometheus.NewDesc("test_size_bytes", "size", nil, nil)
+	reg.MustRegister(counter, gauge, histogram, constCollector{
+		prometheus.MustNewConstSummary(summary, 0, math.NaN(), map[float64]float64{0.5: math.NaN()}),
+	})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	push, err := NewMetricsPush(recv.opts(), "harbor.dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := push(mfs); err != nil {
+		t.Fatal(err)
+	}
+
+	var req metricsRequest
+	select {
+	case req = <-recv.metrics:
+	default:
+		t.Fatal("no metrics are exported")
+	}
+	metrics := make(map[string]metric)
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	c := metrics["test_requests_total"]
+	if c.Sum == nil || !c.Sum.IsMonotonic || c.Sum.AggregationTemporality != aggregationTemporalityCumulative || len(c.Sum.DataPoints) != 1 {
+		t.Fatalf("counter is %+v, want a cumulative monotonic sum", c)
+	}
+	if dp := c.Sum.DataPoints[0]; dp.AsDouble != 3 || stringValue(attrs(dp.Attributes)["code"]) != "200" || dp.StartTimeUnixNano == "" {
+		t.Errorf("counter point is %+v, want 3 of code 200", dp)
+	}
+
+	g := metrics["test_up"]
+	if g.Gauge == nil || len(g.Gauge.DataPoints) != 1 || g.Gauge.DataPoints[0].AsDouble != 1 {
+		t.Errorf("gauge is %+v, want 1", g)
+	}
+
+	h := metrics["test_duration_seconds"]
+	if h.Histogram == nil || len(h.Histogram.DataPoints) != 1 {
+		t.Fatalf("histogram is %+v", h)
+	}
+	hp := h.Histogram.DataPoints[0]
+	if hp.Count != "3" || hp.Sum == nil || *hp.Sum != 5.55 {
+		t.Errorf("histogram count and sum are %s and %v, want 3 and 5.55", hp.Count, hp.Sum)
+	}
+	if len(hp.ExplicitBounds) != 2 || len(hp.BucketCounts) != 3 ||
+		hp.BucketCounts[0] != "1" || hp.BucketCounts[1] != "1" || hp.BucketCounts[2] != "1" {
+		t.Errorf("histogram buckets are %v %v, want [0.1 1] [1 1 1]", hp.ExplicitBounds, hp.BucketCounts)
+	}
+
+	s := metrics["test_size_bytes"]
+	if s.Summary == nil || len(s.Summary.DataPoints) != 1 {
+		t.Fatalf("summary is %+v", s)
+	}
+	if sp := s.Summary.DataPoints[0]; sp.Sum != nil || len(sp.QuantileValues) != 0 || sp.Count != "0" {
+		t.Errorf("summary point is %+v, want the NaN sum and quantile left out", sp)
+	}
+}
+
+// constCollector collects the const metrics.
+type constCollector []prometheus.Metric
+
+func (c constCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c {
+		ch <- m.Desc()
+	}
+}
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
@@ -0,0 +1,157 @@
+package otlp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zhangguanzhang/harbor_exporter/collector"
+)
+
+// check interface
+var _ collector.Tracer = (*Tracer)(nil)
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	statusCodeOk    = 1
+	statusCodeError = 2
+)
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type spanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            spanStatus `json:"status"`
+}
+
+// Tracer exports a trace when its root span ends, i.e. once per scrape.
+type Tracer struct {
+	client   *client
+	instance string
+}
+
+func NewTracer(opts *Opts, instance string) (*Tracer, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracer{client: c, instance: instance}, nil
+}
+
+// trace collects the ended spans until the root span ends.
+type trace struct {
+	id    string
+	mu    sync.Mutex
+	spans []spanData
+}
+
+type span struct {
+	tracer *Tracer
+	trace  *trace
+	root   bool
+
+	mu   sync.Mutex
+	data spanData
+}
+
+// Start implements collector.Tracer.
+func (t *Tracer) Start(parent collector.Span, name string) collector.Span {
+	s := &span{tracer: t}
+	s.data.Name = name
+	s.data.SpanID = randomID(8)
+	s.data.StartTimeUnixNano = unixNano(time.Now())
+	s.data.Kind = spanKindInternal
+
+	if p, ok := parent.(*span); ok {
+		s.trace = p.trace
+		s.data.TraceID = p.data.TraceID
+		s.data.ParentSpanID = p.data.SpanID
+	} else {
+		s.root = true
+		s.trace = &trace{id: randomID(16)}
+		s.data.TraceID = s.trace.id
+	}
+	return s
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attribute(key, value))
+	// the requests to harbor
+	if key == "http.method" {
+		s.data.Kind = spanKindClient
+	}
+	s.mu.Unlock()
+}
+
+func (s *span) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.data.Status = spanStatus{Code: statusCodeOk}
+		return
+	}
+	s.data.Status = spanStatus{Code: statusCodeError, Message: err.Error()}
+	s.data.Attributes = append(s.data.Attributes, attribute("error", true))
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	s.data.EndTimeUnixNano = unixNano(time.Now())
+	data := s.data
+	s.mu.Unlock()
+
+	s.trace.mu.Lock()
+	s.trace.spans = append(s.trace.spans, data)
+	spans := s.trace.spans
+	s.trace.mu.Unlock()
+
+	if !s.root {
+		return
+	}
+
+	// don't block the scrape on the collector
+	go func() {
+		req := tracesRequest{ResourceSpans: []resourceSpans{{
+			Resource:   newResource(s.tracer.instance),
+			ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: spans}},
+		}}}
+		if err := s.tracer.client.post("/v1/traces", req); err != nil {
+			log.WithField("trace_id", s.trace.id).Warn(err)
+		}
+	}()
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package pusher
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// authDoer sets the credentials before sending the request.
+type authDoer struct {
+	client *http.Client
+	auth   func(*http.Request) error
+}
+
+func (d authDoer) Do(req *http.Request) (*http.Response, error) {
+	if err := d.auth(req); err != nil {
+		return nil, err
+	}
+	return d.client.Do(req)
+}
+
+// newGatewayPush replaces the metrics of the job and instance grouping on the Pushgateway every push,
+// so the series which are gone in harbor are gone in the Pushgateway as well.
+func newGatewayPush(url, job, instance string, client *http.Client, auth func(*http.Request) error) pushFunc {
+	return func(mfs []*dto.MetricFamily) error {
+		return push.New(url, job).
+			Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })).
+			Grouping("instance", instance).
+			Client(authDoer{client: client, auth: auth}).
+			Push()
+	}
+}
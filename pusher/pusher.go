@@ -0,0 +1,241 @@
+package pusher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	namespace = "harbor"
+	subsystem = "exporter"
+
+	ModePushgateway = "pushgateway"
+	ModeRemoteWrite = "remote_write"
+	ModeOTLP        = "otlp"
+)
+
+// Opts of the push modes, nothing is pushed if both urls are empty.
+type Opts struct {
+	GatewayURL     string
+	RemoteWriteURL string
+	Job            string
+	Interval       time.Duration
+	Timeout        time.Duration
+
+	Username        string
+	PasswordFile    string
+	BearerTokenFile string
+}
+
+// DefaultOpts returns the opts with the defaults of the flags, set the urls to push.
+func DefaultOpts() *Opts {
+	return &Opts{
+		Job:      "harbor_exporter",
+		Interval: time.Minute,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// AddFlag adds the flags to the global flag set.
+func (o *Opts) AddFlag() {
+	o.AddFlags(flag.CommandLine)
+}
+
+// AddFlags adds the flags to fs, the defaults are the ones of DefaultOpts.
+func (o *Opts) AddFlags(fs *flag.FlagSet) {
+	d := DefaultOpts()
+	fs.StringVar(&o.GatewayURL, "push.gateway-url", d.GatewayURL, "Pushgateway url the metrics are pushed to, e.g. http://pushgateway:9091")
+	fs.StringVar(&o.RemoteWriteURL, "push.remote-write-url", d.RemoteWriteURL, "Prometheus remote write url the metrics are pushed to, e.g. http://prometheus:9090/api/v1/write")
+	fs.StringVar(&o.Job, "push.job", d.Job, "The job label of the pushed metrics.")
+	fs.DurationVar(&o.Interval, "push.interval", d.Interval, "Interval to scrape harbor and push the metrics.")
+	fs.DurationVar(&o.Timeout, "push.timeout", d.Timeout, "Timeout on pushing the metrics.")
+	fs.StringVar(&o.Username, "push.basic-auth-username", d.Username, "The basic auth username of the push endpoints.")
+	fs.StringVar(&o.PasswordFile, "push.basic-auth-password-file", d.PasswordFile, "The file containing the basic auth password of the push endpoints.")
+	fs.StringVar(&o.BearerTokenFile, "push.bearer-token-file", d.BearerTokenFile, "The file containing the bearer token of the push endpoints.")
+}
+
+// Enabled reports whether any push mode is configured.
+func (o *Opts) Enabled() bool {
+	return o.GatewayURL != "" || o.RemoteWriteURL != ""
+}
+
+// Metrics of the pushes, they could be registered to both the pushed and the scraped registry.
+type Metrics struct {
+	Pushes       *prometheus.CounterVec
+	Failures     *prometheus.CounterVec
+	LastSuccess  *prometheus.GaugeVec
+	PushDuration *prometheus.GaugeVec
+}
+
+func NewMetrics() Metrics {
+	return Metrics{
+		Pushes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "pushes_total",
+			Help:      "Total number of times the metrics were pushed.",
+		}, []string{"mode"}),
+		Failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "push_failures_total",
+			Help:      "Total number of times pushing the metrics failed.",
+		}, []string{"mode"}),
+		LastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "push_last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful push.",
+		}, []string{"mode"}),
+		PushDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "push_duration_seconds",
+			Help:      "Time consuming of the last push, including the harbor scrape.",
+		}, []string{"mode"}),
+	}
+}
+
+func (m Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.Pushes.Describe(ch)
+	m.Failures.Describe(ch)
+	m.LastSuccess.Describe(ch)
+	m.PushDuration.Describe(ch)
+}
+
+func (m Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.Pushes.Collect(ch)
+	m.Failures.Collect(ch)
+	m.LastSuccess.Collect(ch)
+	m.PushDuration.Collect(ch)
+}
+
+// pushFunc pushes the metrics gathered once, the same metrics are passed to every mode.
+type pushFunc func(mfs []*dto.MetricFamily) error
+
+// Pusher periodically gathers the metrics and pushes them by the configured modes.
+type Pusher struct {
+	opts     *Opts
+	metrics  Metrics
+	gatherer prometheus.Gatherer
+	modes    map[string]pushFunc
+	order    []string // the modes in the order they were added
+}
+
+// New creates the Pusher, instance is the grouping key of the pushed metrics,
+// usually the harbor host.
+func New(opts *Opts, metrics Metrics, g prometheus.Gatherer, instance string) (*Pusher, error) {
+	client := &http.Client{Timeout: opts.Timeout}
+	auth, err := opts.authorizer()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pusher{
+		opts:     opts,
+		metrics:  metrics,
+		gatherer: g,
+		modes:    map[string]pushFunc{},
+	}
+	if opts.GatewayURL != "" {
+		p.AddMode(ModePushgateway, newGatewayPush(opts.GatewayURL, opts.Job, instance, client, auth))
+	}
+	if opts.RemoteWriteURL != "" {
+		p.AddMode(ModeRemoteWrite, newRemoteWritePush(opts.RemoteWriteURL, opts.Job, instance, client, auth))
+	}
+	return p, nil
+}
+
+// AddMode pushes the metrics by push as well, e.g. the OTLP metrics exporter.
+func (p *Pusher) AddMode(mode string, push func(mfs []*dto.MetricFamily) error) {
+	if _, ok := p.modes[mode]; !ok {
+		p.order = append(p.order, mode)
+	}
+	p.modes[mode] = push
+	// expose the failures before the first push
+	p.metrics.Failures.WithLabelValues(mode)
+	p.metrics.Pushes.WithLabelValues(mode)
+}
+
+// Run pushes the metrics every interval until stop is closed.
+func (p *Pusher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.pushAll()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushAll scrapes harbor once by gathering the metrics and pushes them by every mode,
+// a failed gathering fails all the modes.
+func (p *Pusher) pushAll() {
+	start := time.Now()
+	mfs, gatherErr := p.gatherer.Gather()
+	gathering := time.Since(start)
+
+	for _, mode := range p.order {
+		push := p.modes[mode]
+		err := gatherErr
+		start := time.Now()
+		if err == nil {
+			err = push(mfs)
+		}
+		p.metrics.PushDuration.WithLabelValues(mode).Set((gathering + time.Since(start)).Seconds())
+		p.metrics.Pushes.WithLabelValues(mode).Inc()
+		if err != nil {
+			log.WithField("mode", mode).Error(err)
+			p.metrics.Failures.WithLabelValues(mode).Inc()
+			continue
+		}
+		p.metrics.LastSuccess.WithLabelValues(mode).SetToCurrentTime()
+	}
+}
+
+// authorizer sets the credentials on the push requests, the files are read on every push
+// so the rotated secrets are picked up.
+func (o *Opts) authorizer() (func(*http.Request) error, error) {
+	if o.Username != "" && o.BearerTokenFile != "" {
+		return nil, fmt.Errorf("only one of basic auth and bearer token could be set for pushing")
+	}
+
+	switch {
+	case o.Username != "":
+		return func(req *http.Request) error {
+			var password string
+			if o.PasswordFile != "" {
+				b, err := ioutil.ReadFile(o.PasswordFile)
+				if err != nil {
+					return err
+				}
+				password = strings.TrimSpace(string(b))
+			}
+			req.SetBasicAuth(o.Username, password)
+			return nil
+		}, nil
+	case o.BearerTokenFile != "":
+		return func(req *http.Request) error {
+			b, err := ioutil.ReadFile(o.BearerTokenFile)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(b)))
+			return nil
+		}, nil
+	default:
+		return func(*http.Request) error { return nil }, nil
+	}
+}
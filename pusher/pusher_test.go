@@ -0,0 +1,53 @@
+package pusher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPushAllGathersOnce(t *testing.T) {
+	var (
+		gathered  int
+		gatherErr error
+	)
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		gathered++
+		return []*dto.MetricFamily{{}}, gatherErr
+	})
+
+	metrics := NewMetrics()
+	p, err := New(&Opts{}, metrics, g, "harbor.dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed := map[string][]*dto.MetricFamily{}
+	for _, mode := range []string{ModePushgateway, ModeRemoteWrite, ModeOTLP} {
+		mode := mode
+		p.AddMode(mode, func(mfs []*dto.MetricFamily) error {
+			pushed[mode] = mfs
+			return nil
+		})
+	}
+
+	p.pushAll()
+	if gathered != 1 {
+		t.Errorf("gathered %d times, want once for all the modes", gathered)
+	}
+	for mode, mfs := range pushed {
+		if len(mfs) != 1 || mfs[0] != pushed[ModePushgateway][0] {
+			t.Errorf("mode %s pushed %v, want the same metrics as the others", mode, mfs)
+		}
+	}
+
+	gatherErr = errors.New("gather")
+	p.pushAll()
+	for _, mode := range p.order {
+		if v := testutil.ToFloat64(metrics.Failures.WithLabelValues(mode)); v != 1 {
+			t.Errorf("failures of %s are %v, want 1 after the failed gathering", mode, v)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package pusher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type label struct {
+	name, value string
+}
+
+type timeSeries struct {
+	labels []label
+	value  float64
+}
+
+// newRemoteWritePush sends the gathered metrics by the Prometheus remote write protocol 0.1.0,
+// the job and instance labels are added like a scrape would do.
+func newRemoteWritePush(url, job, instance string, client *http.Client, auth func(*http.Request) error) pushFunc {
+	return func(mfs []*dto.MetricFamily) error {
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		var series []timeSeries
+		for _, mf := range mfs {
+			series = appendSeries(series, mf)
+		}
+		for i := range series {
+			series[i].labels = append(series[i].labels, label{"job", job}, label{"instance", instance})
+			sort.Slice(series[i].labels, func(a, b int) bool {
+				return series[i].labels[a].name < series[i].labels[b].name
+			})
+		}
+
+		body := snappy.Encode(nil, encodeWriteRequest(series, now))
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+		req.Header.Set("User-Agent", "harbor_exporter")
+		if err := auth(req); err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("remote write %s http-statuscode: %s %s", url, resp.Status, bytes.TrimSpace(msg))
+		}
+		return nil
+	}
+}
+
+// appendSeries flattens the metric family like the exposition format,
+// summaries and histograms are split into the _sum, _count and quantile/bucket series.
+func appendSeries(series []timeSeries, mf *dto.MetricFamily) []timeSeries {
+	name := mf.GetName()
+	for _, m := range mf.GetMetric() {
+		labels := make([]label, 0, len(m.GetLabel())+1)
+		for _, lp := range m.GetLabel() {
+			labels = append(labels, label{lp.GetName(), lp.GetValue()})
+		}
+		with := func(metricName string, extra ...label) []label {
+			l := make([]label, 0, len(labels)+len(extra)+1)
+			l = append(l, label{"__name__", metricName})
+			l = append(l, labels...)
+			return append(l, extra...)
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			series = append(series, timeSeries{with(name), m.GetCounter().GetValue()})
+		case dto.MetricType_GAUGE:
+			series = append(series, timeSeries{with(name), m.GetGauge().GetValue()})
+		case dto.MetricType_UNTYPED:
+			series = append(series, timeSeries{with(name), m.GetUntyped().GetValue()})
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			for _, q := range s.GetQuantile() {
+				series = append(series, timeSeries{with(name, label{"quantile", formatFloat(q.GetQuantile())}), q.GetValue()})
+			}
+			series = append(series,
+				timeSeries{with(name + "_sum"), s.GetSampleSum()},
+				timeSeries{with(name + "_count"), float64(s.GetSampleCount())},
+			)
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			for _, b := range h.GetBucket() {
+				series = append(series, timeSeries{with(name+"_bucket", label{"le", formatFloat(b.GetUpperBound())}), float64(b.GetCumulativeCount())})
+			}
+			series = append(series,
+				timeSeries{with(name+"_bucket", label{"le", "+Inf"}), float64(h.GetSampleCount())},
+				timeSeries{with(name + "_sum"), h.GetSampleSum()},
+				timeSeries{with(name + "_count"), float64(h.GetSampleCount())},
+			)
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes the prometheus.WriteRequest protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries, timestamp int64) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestamp))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
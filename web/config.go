@@ -0,0 +1,201 @@
+// Package web serves the exporter endpoints with TLS and basic auth configured by a web config file,
+// the format is the one of the prometheus exporter-toolkit, see
+// https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the web config file.
+type Config struct {
+	TLSConfig  TLSStruct         `yaml:"tls_server_config"`
+	HTTPConfig HTTPStruct        `yaml:"http_server_config"`
+	Users      map[string]string `yaml:"basic_auth_users"`
+}
+
+// TLSStruct is the TLS settings of the server, the paths are relative to the config file.
+type TLSStruct struct {
+	TLSCertPath              string     `yaml:"cert_file"`
+	TLSKeyPath               string     `yaml:"key_file"`
+	ClientAuth               string     `yaml:"client_auth_type"`
+	ClientCAs                string     `yaml:"client_ca_file"`
+	CipherSuites             []cipher   `yaml:"cipher_suites"`
+	CurvePreferences         []curve    `yaml:"curve_preferences"`
+	MinVersion               tlsVersion `yaml:"min_version"`
+	MaxVersion               tlsVersion `yaml:"max_version"`
+	PreferServerCipherSuites bool       `yaml:"prefer_server_cipher_suites"`
+}
+
+// HTTPStruct is the HTTP settings of the server.
+type HTTPStruct struct {
+	HTTP2 bool `yaml:"http2"`
+}
+
+func (t *TLSStruct) setDirectory(dir string) {
+	for _, p := range []*string{&t.TLSCertPath, &t.TLSKeyPath, &t.ClientCAs} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+}
+
+func getConfig(configPath string) (*Config, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{
+		TLSConfig: TLSStruct{
+			MinVersion:               tls.VersionTLS12,
+			MaxVersion:               tls.VersionTLS13,
+			PreferServerCipherSuites: true,
+		},
+		HTTPConfig: HTTPStruct{HTTP2: true},
+	}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, err
+	}
+	c.TLSConfig.setDirectory(filepath.Dir(configPath))
+	return c, nil
+}
+
+// tlsEnabled reports whether the server should serve TLS, a config file
+// with only basic_auth_users serves plain HTTP.
+func (t *TLSStruct) tlsEnabled() (bool, error) {
+	switch {
+	case t.TLSCertPath == "" && t.TLSKeyPath == "" && t.ClientAuth == "" && t.ClientCAs == "":
+		return false, nil
+	case t.TLSCertPath == "":
+		return false, fmt.Errorf("missing cert_file")
+	case t.TLSKeyPath == "":
+		return false, fmt.Errorf("missing key_file")
+	}
+	return true, nil
+}
+
+// ConfigToTLSConfig generates the golang tls.Config from the TLSStruct config.
+func ConfigToTLSConfig(c *TLSStruct) (*tls.Config, error) {
+	if enabled, err := c.tlsEnabled(); err != nil || !enabled {
+		if err == nil {
+			err = fmt.Errorf("missing cert_file and key_file")
+		}
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertPath, c.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load X509KeyPair: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:               uint16(c.MinVersion),
+		MaxVersion:               uint16(c.MaxVersion),
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+		Certificates:             []tls.Certificate{cert},
+	}
+
+	for _, cs := range c.CipherSuites {
+		cfg.CipherSuites = append(cfg.CipherSuites, uint16(cs))
+	}
+	for _, cp := range c.CurvePreferences {
+		cfg.CurvePreferences = append(cfg.CurvePreferences, tls.CurveID(cp))
+	}
+
+	if c.ClientCAs != "" {
+		clientCAPool := x509.NewCertPool()
+		clientCAFile, err := ioutil.ReadFile(c.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		if !clientCAPool.AppendCertsFromPEM(clientCAFile) {
+			return nil, fmt.Errorf("no certificate found in client_ca_file %s", c.ClientCAs)
+		}
+		cfg.ClientCAs = clientCAPool
+	}
+
+	switch c.ClientAuth {
+	case "RequestClientCert":
+		cfg.ClientAuth = tls.RequestClientCert
+	case "RequireAnyClientCert", "RequireClientCert": // Preserved for backwards compatibility.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	case "VerifyClientCertIfGiven":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case "RequireAndVerifyClientCert":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case "", "NoClientCert":
+		cfg.ClientAuth = tls.NoClientCert
+	default:
+		return nil, fmt.Errorf("invalid ClientAuth: %s", c.ClientAuth)
+	}
+
+	if c.ClientCAs != "" && cfg.ClientAuth == tls.NoClientCert {
+		return nil, fmt.Errorf("client CA's have been configured without a Client Auth Policy")
+	}
+
+	return cfg, nil
+}
+
+type cipher uint16
+
+func (c *cipher) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	for _, cs := range tls.CipherSuites() {
+		if cs.Name == s {
+			*c = cipher(cs.ID)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown cipher: %s", s)
+}
+
+type curve tls.CurveID
+
+var curves = map[string]curve{
+	"CurveP256": curve(tls.CurveP256),
+	"CurveP384": curve(tls.CurveP384),
+	"CurveP521": curve(tls.CurveP521),
+	"X25519":    curve(tls.X25519),
+}
+
+func (c *curve) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if curveid, ok := curves[s]; ok {
+		*c = curveid
+		return nil
+	}
+	return fmt.Errorf("unknown curve: %s", s)
+}
+
+type tlsVersion uint16
+
+var tlsVersions = map[string]tlsVersion{
+	"TLS13": tls.VersionTLS13,
+	"TLS12": tls.VersionTLS12,
+	"TLS11": tls.VersionTLS11,
+	"TLS10": tls.VersionTLS10,
+}
+
+func (tv *tlsVersion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if v, ok := tlsVersions[s]; ok {
+		*tv = v
+		return nil
+	}
+	return fmt.Errorf("unknown TLS version: %s", s)
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Validate checks the web config file, the server refuses to start with an invalid one.
+func Validate(configPath string) error {
+	if configPath == "" {
+		return nil
+	}
+
+	c, err := getConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	for user, hash := range c.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash of the user %q: %s", user, err)
+		}
+	}
+
+	enabled, err := c.TLSConfig.tlsEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+	_, err = ConfigToTLSConfig(&c.TLSConfig)
+	return err
+}
+
+// ListenAndServe starts the server on its Addr with the TLS settings and the basic auth users of
+// the web config file, which is reread on every request and TLS handshake so the renewed certificates
+// and the changed users are picked up. An empty configPath serves plain HTTP without auth.
+func ListenAndServe(server *http.Server, configPath string) error {
+	if configPath == "" {
+		log.Info("TLS is disabled.")
+		return server.ListenAndServe()
+	}
+
+	if err := Validate(configPath); err != nil {
+		return err
+	}
+	c, err := getConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	server.Handler = &webHandler{
+		handler:    server.Handler,
+		configPath: configPath,
+		cache:      newCache(),
+	}
+
+	if enabled, _ := c.TLSConfig.tlsEnabled(); !enabled {
+		log.Info("TLS is disabled.")
+		return server.ListenAndServe()
+	}
+	log.Info("TLS is enabled.")
+
+	nextProtos := []string{"h2", "http/1.1"}
+	if !c.HTTPConfig.HTTP2 {
+		nextProtos = []string{"http/1.1"}
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	// the whole config is reloaded on every handshake, e.g. the renewed certificates
+	server.TLSConfig = &tls.Config{
+		MinVersion:               uint16(c.TLSConfig.MinVersion),
+		MaxVersion:               uint16(c.TLSConfig.MaxVersion),
+		PreferServerCipherSuites: c.TLSConfig.PreferServerCipherSuites,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c, err := getConfig(configPath)
+			if err != nil {
+				return nil, err
+			}
+			cfg, err := ConfigToTLSConfig(&c.TLSConfig)
+			if err != nil {
+				return nil, err
+			}
+			cfg.NextProtos = nextProtos
+			return cfg, nil
+		},
+	}
+
+	addr := server.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// the certificates are set by GetConfigForClient
+	return server.ServeTLS(listener, "", "")
+}
+
+// webHandler checks the basic auth users of the web config file before handler.
+type webHandler struct {
+	handler    http.Handler
+	configPath string
+	cache      *cache
+}
+
+func (u *webHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := getConfig(u.configPath)
+	if err != nil {
+		log.WithField("file", u.configPath).Errorf("unable to parse the web config file: %s", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(c.Users) == 0 {
+		u.handler.ServeHTTP(w, r)
+		return
+	}
+
+	user, pass, auth := r.BasicAuth()
+	if auth {
+		hashedPassword, validUser := c.Users[user]
+		if !validUser {
+			// compare with a dummy hash anyway, so the unknown users take the same time
+			hashedPassword = "$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi"
+		}
+
+		key := sha256.Sum256([]byte(user + "\x00" + hashedPassword + "\x00" + pass))
+		authOk, ok := u.cache.get(key)
+		if !ok {
+			authOk = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(pass)) == nil
+			u.cache.set(key, authOk)
+		}
+
+		if authOk && validUser {
+			u.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("WWW-Authenticate", "Basic")
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// cache keeps the results of the bcrypt comparisons, which are slow on purpose.
+type cache struct {
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]bool
+}
+
+const maxCacheSize = 100
+
+func newCache() *cache {
+	return &cache{cache: make(map[[sha256.Size]byte]bool)}
+}
+
+func (c *cache) get(key [sha256.Size]byte) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.cache[key]
+	return v, ok
+}
+
+func (c *cache) set(key [sha256.Size]byte, value bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= maxCacheSize {
+		// evict a random entry, the map iteration order is random
+		for k := range c.cache {
+			delete(c.cache, k)
+			break
+		}
+	}
+	c.cache[key] = value
+}
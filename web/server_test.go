@@ -0,0 +1,314 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newCert signs a certificate for localhost with parent, a nil parent makes a self signed CA.
+func newCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+type testPKI struct {
+	dir     string
+	ca      *testCert
+	server  *testCert
+	client  *testCert
+	unknown *testCert
+}
+
+// newPKI writes ca.pem, server.pem and server.key in a temporary directory, the client certificate
+// is signed by the CA and the unknown one by another CA.
+func newPKI(t *testing.T) *testPKI {
+	p := &testPKI{dir: t.TempDir()}
+	p.ca = newCert(t, "ca", nil)
+	p.server = newCert(t, "server", p.ca)
+	p.client = newCert(t, "client", p.ca)
+	p.unknown = newCert(t, "unknown", newCert(t, "other ca", nil))
+	writeFile(t, p.dir, "ca.pem", string(p.ca.certPEM()))
+	writeFile(t, p.dir, "server.pem", string(p.server.certPEM()))
+	writeFile(t, p.dir, "server.key", string(p.server.keyPEM(t)))
+	return p
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func hashPassword(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// serve starts ListenAndServe on a free port with the web config and returns its address.
+func serve(t *testing.T, configPath string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	server := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- ListenAndServe(server, configPath) }()
+	t.Cleanup(func() { server.Close() })
+
+	for i := 0; i < 100; i++ {
+		select {
+		case err := <-errc:
+			t.Fatalf("ListenAndServe: %s", err)
+		default:
+		}
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the server is not listening on %s", addr)
+	return ""
+}
+
+func request(client *http.Client, url, user, password string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func tlsClient(p *testPKI, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+	}}
+}
+
+func TestServeTLS(t *testing.T) {
+	p := newPKI(t)
+	config := writeFile(t, p.dir, "web.yml", `
+tls_server_config:
+  cert_file: server.pem
+  key_file: server.key
+`)
+	addr := serve(t, config)
+
+	code, err := request(tlsClient(p), "https://"+addr, "", "")
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("https request: code %d, err %v", code, err)
+	}
+	if code, err := request(http.DefaultClient, "http://"+addr, "", ""); err == nil && code == http.StatusOK {
+		t.Errorf("plain http request is served on a TLS server")
+	}
+}
+
+func TestServeClientCert(t *testing.T) {
+	p := newPKI(t)
+	config := writeFile(t, p.dir, "web.yml", `
+tls_server_config:
+  cert_file: server.pem
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: ca.pem
+`)
+	addr := serve(t, config)
+
+	tests := []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"client cert signed by the CA", []tls.Certificate{p.client.tlsCert(t)}, true},
+		{"no client cert", nil, false},
+		{"client cert of an unknown CA", []tls.Certificate{p.unknown.tlsCert(t)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := request(tlsClient(p, tt.certs...), "https://"+addr, "", "")
+			if tt.ok && (err != nil || code != http.StatusOK) {
+				t.Errorf("code %d, err %v, want 200", code, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("code %d, want a refused handshake", code)
+			}
+		})
+	}
+}
+
+func TestServeBasicAuth(t *testing.T) {
+	p := newPKI(t)
+	config := writeFile(t, p.dir, "web.yml", fmt.Sprintf(`
+basic_auth_users:
+  alice: %s
+`, hashPassword(t, "secret")))
+	addr := serve(t, config)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		code     int
+	}{
+		{"good user", "alice", "secret", http.StatusOK},
+		{"the good user twice, from the cache", "alice", "secret", http.StatusOK},
+		{"wrong password", "alice", "wrong", http.StatusUnauthorized},
+		{"unknown user", "bob", "secret", http.StatusUnauthorized},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := request(http.DefaultClient, "http://"+addr, tt.user, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("code %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := newPKI(t)
+	writeFile(t, p.dir, "empty.pem", "")
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"basic auth only", "basic_auth_users:\n  alice: " + hashPassword(t, "secret"), ""},
+		{"tls", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key", ""},
+		{"client ca", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  client_auth_type: RequireAndVerifyClientCert\n  client_ca_file: ca.pem", ""},
+		{"unknown field", "tls_server_config:\n  cert: server.pem", "field cert not found"},
+		{"invalid hash", "basic_auth_users:\n  alice: secret", `invalid bcrypt hash of the user "alice"`},
+		{"missing key", "tls_server_config:\n  cert_file: server.pem", "missing key_file"},
+		{"missing cert", "tls_server_config:\n  key_file: server.key", "missing cert_file"},
+		{"unreadable cert", "tls_server_config:\n  cert_file: none.pem\n  key_file: server.key", "failed to load X509KeyPair"},
+		{"invalid client auth", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  client_auth_type: Sometimes", "invalid ClientAuth"},
+		{"client ca without client auth", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  client_ca_file: ca.pem", "without a Client Auth Policy"},
+		{"empty client ca", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  client_auth_type: RequireAndVerifyClientCert\n  client_ca_file: empty.pem", "no certificate found"},
+		{"unknown cipher", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  cipher_suites: [TLS_NONE]", "unknown cipher"},
+		{"unknown curve", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  curve_preferences: [P1]", "unknown curve"},
+		{"unknown tls version", "tls_server_config:\n  cert_file: server.pem\n  key_file: server.key\n  min_version: TLS9", "unknown TLS version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(writeFile(t, p.dir, "web.yml", tt.config))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if err := Validate(""); err != nil {
+		t.Errorf("no config file: %s", err)
+	}
+	if err := Validate(filepath.Join(p.dir, "none.yml")); err == nil {
+		t.Errorf("missing config file is valid")
+	}
+}